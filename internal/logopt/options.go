@@ -36,6 +36,20 @@ func WithExtraFieldsV(fields ...interface{}) LoggerOption {
 	}
 }
 
+// WithMergedFields creates a logopt.LoggerOption to log with extra fields, which will be merged into the existing fields rather than replace them.
+func WithMergedFields(fields map[string]interface{}) LoggerOption {
+	return func(extra *loggerOptions) {
+		merged := make(map[string]interface{}, len(extra.Fields)+len(fields)) // copy to avoid modifying the given map
+		for k, v := range extra.Fields {
+			merged[k] = v
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		extra.Fields = merged
+	}
+}
+
 // NewLoggerOptions creates a loggerOptions from given LoggerOption-s.
 func NewLoggerOptions(options []LoggerOption) *loggerOptions {
 	out := &loggerOptions{
@@ -52,6 +52,10 @@ func TestLoggerOptions(t *testing.T) {
 		{[]LoggerOption{WithExtraFieldsV(3, 4), WithExtraText("test")}, " | test", logrus.Fields{"3": 4}},
 		{[]LoggerOption{WithExtraFields(map[string]interface{}{"1": 2}), WithExtraFieldsV(3, 4)}, "", logrus.Fields{"3": 4}},
 		{[]LoggerOption{WithExtraFieldsV(3, 4), WithExtraFields(map[string]interface{}{"1": 2})}, "", logrus.Fields{"1": 2}},
+		{[]LoggerOption{WithMergedFields(map[string]interface{}{"1": 2})}, "", logrus.Fields{"1": 2}},
+		{[]LoggerOption{WithExtraFieldsV(3, 4), WithMergedFields(map[string]interface{}{"1": 2})}, "", logrus.Fields{"3": 4, "1": 2}},
+		{[]LoggerOption{WithMergedFields(map[string]interface{}{"1": 2}), WithExtraFieldsV(3, 4)}, "", logrus.Fields{"3": 4}},
+		{[]LoggerOption{WithMergedFields(map[string]interface{}{"1": 2}), WithMergedFields(map[string]interface{}{"1": 3})}, "", logrus.Fields{"1": 3}},
 	} {
 		ops := NewLoggerOptions(tc.give)
 		msg := ""
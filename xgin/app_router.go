@@ -4,11 +4,16 @@ import (
 	"fmt"
 	"github.com/Aoi-hosizora/ahlib/xnumber"
 	"github.com/Aoi-hosizora/ahlib/xreflect"
+	"github.com/Aoi-hosizora/ahlib/xtime"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"path"
 	"reflect"
+	"regexp"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 )
 
 // AppRouter represents a group of routers with gin.Engine and gin.IRouter, is a replacement of gin's trie router model,
@@ -42,16 +47,58 @@ import (
 // 4. xgin.AppRouter does not support:
 // 	ap.GET(":a", fn)
 // 	ap.GET(":b", fn) // X
+//
+// 5. xgin.AppRouter's precedence: (static layer beats parameter layer, checked from left to right)
+// 	ap.GET(":a/b", fn)
+// 	ap.GET("a/:b", fn) // "/a/b" is handled by "a/:b" regardless of the registration order
+//
+// 6. xgin.AppRouter supports trailing catch-all parameter: (note that the fake path conflicts with other routes of the same gin.IRouter)
+// 	ap.GET("files/meta", fn)
+// 	ap.GET("files/:id", fn)
+// 	ap.GET("files/*path", fn) // "/files/a/b" is handled with c.Param("path") == "/a/b"
+//
+// 7. xgin.AppRouter supports parameter constraint: (named type int, uuid, date, or regexp without "/")
+// 	ap.GET(":id<int>", fn)        // "/1" is handled with c.Param("id") == "1"
+// 	ap.GET(":code<[a-z]{3}>", fn) // "/abc" is handled with c.Param("code") == "abc"
+// 	ap.GET(":slug", fn)           // "/a-b" is handled with c.Param("slug") == "a-b"
 type AppRouter struct {
 	engine *gin.Engine
 	router gin.IRouter
 	groups [][]*routerConfig // groups: []method, method: []*routerConfig.
 
+	tries map[string]*routeTrieNode // method: trie, compiled by Register.
+	opt   *appRouterOptions
+
 	noRouter gin.HandlersChain // for 404
 	noMethod gin.HandlersChain // for 405
 }
 
-// NewAppRouter creates an empty AppRouter using given gin.Engine and gin.IRouter.
+// appRouterOptions represents some options for AppRouter, set by AppRouterOption.
+type appRouterOptions struct {
+	unreachable func(router, existed *routerConfig)
+}
+
+// AppRouterOption represents an option for AppRouter, can be created by WithXXX functions.
+type AppRouterOption func(*appRouterOptions)
+
+// WithUnreachableRouteWarning creates an AppRouterOption for warning the routes which can never be reached in AppRouter.Register, such as
+// "a/:y" registered after "a/:x". Given handler will be invoked with the unreachable route and the existing route which handles instead,
+// and a warning like "[XGIN-warning] GET ~/a/:y is unreachable, because it is shadowed by ~/a/:x" will be printed if handler is nil.
+// Unreachable routes are ignored silently by default.
+func WithUnreachableRouteWarning(handler func(method, relativePath, existedPath string)) AppRouterOption {
+	if handler == nil {
+		handler = func(method, relativePath, existedPath string) {
+			fmt.Printf("[XGIN-warning] %s ~/%s is unreachable, because it is shadowed by ~/%s\n", method, relativePath, existedPath)
+		}
+	}
+	return func(o *appRouterOptions) {
+		o.unreachable = func(router, existed *routerConfig) {
+			handler(router.method, router.relativePath, existed.relativePath)
+		}
+	}
+}
+
+// NewAppRouter creates an empty AppRouter using given gin.Engine, gin.IRouter and AppRouterOption-s.
 //
 // Example:
 // 	app := gin.New()
@@ -62,7 +109,14 @@ type AppRouter struct {
 // 	ap.GET(":a/b", fn)  // /v1/:a/b
 // 	ap.GET(":a/:b", fn) // /v1/:a/:b
 // 	ap.Register()
-func NewAppRouter(engine *gin.Engine, router gin.IRouter) *AppRouter {
+func NewAppRouter(engine *gin.Engine, router gin.IRouter, options ...AppRouterOption) *AppRouter {
+	opt := &appRouterOptions{}
+	for _, op := range options {
+		if op != nil {
+			op(opt)
+		}
+	}
+
 	noRouter := xreflect.GetUnexportedField(reflect.ValueOf(engine).Elem().FieldByName("noRoute")).(gin.HandlersChain)
 	noMethod := xreflect.GetUnexportedField(reflect.ValueOf(engine).Elem().FieldByName("noMethod")).(gin.HandlersChain)
 	if noRouter == nil {
@@ -83,7 +137,7 @@ func NewAppRouter(engine *gin.Engine, router gin.IRouter) *AppRouter {
 	}}, noMethod...)
 
 	return &AppRouter{
-		engine: engine, router: router, groups: [][]*routerConfig{},
+		engine: engine, router: router, groups: [][]*routerConfig{}, tries: map[string]*routeTrieNode{}, opt: opt,
 		noRouter: noRouter, noMethod: noMethod,
 	}
 }
@@ -93,7 +147,60 @@ type routerConfig struct {
 	method       string
 	relativePath string
 	handlers     []gin.HandlerFunc
-	layerNames   []string // generated by relativePath
+	layerNames   []string           // generated by relativePath
+	constraints  []*paramConstraint // generated by layerNames, nil for static layer and parameter without constraint
+	doc          *RouteDoc          // set by AppRouter.Describe
+}
+
+// isCatchAll checks whether the router ends with catch-all parameter, such as "files/*path".
+func (r *routerConfig) isCatchAll() bool {
+	return len(r.layerNames) > 0 && strings.HasPrefix(r.layerNames[len(r.layerNames)-1], "*")
+}
+
+// paramConstraint represents the constraint of a route parameter, such as ":id<int>" and ":code<[a-z]{3}>".
+type paramConstraint struct {
+	source string
+	match  func(value string) bool
+}
+
+// String returns the source of the constraint, returns empty string for nil constraint.
+func (p *paramConstraint) String() string {
+	if p == nil {
+		return ""
+	}
+	return p.source
+}
+
+// namedParamConstraints represents the named parameter constraints, other constraints will be regarded as regexps.
+var namedParamConstraints = map[string]func(value string) bool{
+	"int":  regexp.MustCompile(`^[+-]?[0-9]+$`).MatchString,
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	"date": func(value string) bool {
+		_, err := time.Parse(xtime.RFC3339Date, value)
+		return err == nil
+	},
+}
+
+// newParamConstraint creates a paramConstraint from given named type (int, uuid or date) or regexp, the regexp will match the whole value.
+func newParamConstraint(source string) (*paramConstraint, error) {
+	if match, ok := namedParamConstraints[source]; ok {
+		return &paramConstraint{source: source, match: match}, nil
+	}
+	re, err := regexp.Compile("^(?:" + source + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &paramConstraint{source: source, match: re.MatchString}, nil
+}
+
+// splitParamLayer splits given parameter layer name to its name and constraint, such as ":id<int>" to "id" and "int", and "*path" to "path"
+// and "". Note that the constraint can not contain "/", because it will be split as different layers.
+func splitParamLayer(layerName string) (name, constraint string) {
+	name = layerName[1:]
+	if idx := strings.Index(name, "<"); idx != -1 && strings.HasSuffix(name, ">") {
+		name, constraint = name[:idx], name[idx+1:len(name)-1]
+	}
+	return name, constraint
 }
 
 // GET registers a new list of handlers to given path and uses get method.
@@ -145,9 +252,11 @@ func (a *AppRouter) Any(relativePath string, handlers ...gin.HandlerFunc) {
 const (
 	panicNoHandler         = "xgin: router must have at least one handler"
 	panicAlreadyRegistered = "xgin: handlers are already registered for path '/%s' in existing path '/%s'"
+	panicInvalidCatchAll   = "xgin: catch-all parameter must be named and only allowed at the end of path '/%s'"
+	panicInvalidConstraint = "xgin: invalid parameter constraint '%s' in path '/%s': %v"
 )
 
-// newRouterConfig creates an instance of routerConfig, panics if handlers is empty.
+// newRouterConfig creates an instance of routerConfig, panics if handlers is empty, catch-all parameter or parameter constraint is invalid.
 func newRouterConfig(method string, relativePath string, handlers ...gin.HandlerFunc) *routerConfig {
 	if len(handlers) == 0 {
 		panic(panicNoHandler)
@@ -158,7 +267,22 @@ func newRouterConfig(method string, relativePath string, handlers ...gin.Handler
 	if relativePath != "" {
 		layerNames = strings.Split(relativePath, "/")
 	}
-	return &routerConfig{method: method, relativePath: relativePath, handlers: handlers, layerNames: layerNames}
+	constraints := make([]*paramConstraint, len(layerNames))
+	for i, layerName := range layerNames {
+		if strings.HasPrefix(layerName, "*") && (layerName == "*" || i != len(layerNames)-1) {
+			panic(fmt.Sprintf(panicInvalidCatchAll, relativePath))
+		}
+		if strings.HasPrefix(layerName, ":") {
+			if _, constraint := splitParamLayer(layerName); constraint != "" {
+				c, err := newParamConstraint(constraint)
+				if err != nil {
+					panic(fmt.Sprintf(panicInvalidConstraint, constraint, relativePath, err))
+				}
+				constraints[i] = c
+			}
+		}
+	}
+	return &routerConfig{method: method, relativePath: relativePath, handlers: handlers, layerNames: layerNames, constraints: constraints}
 }
 
 // addToGroups is used to add handlers to AppRouter.groups, note that this method does no check for "_$" prefix router, panics when router paths are conflict.
@@ -183,6 +307,10 @@ func (a *AppRouter) addToGroups(method, relativePath string, handlers []gin.Hand
 					diff = true // one of the layers is not parametered
 					break
 				}
+				if r.constraints[i].String() != router.constraints[i].String() {
+					diff = true // the layers have different constraints
+					break
+				}
 			}
 			if !diff {
 				panic(fmt.Sprintf(panicAlreadyRegistered, r.relativePath, router.relativePath))
@@ -200,6 +328,9 @@ func (a *AppRouter) addToGroups(method, relativePath string, handlers []gin.Hand
 
 // Register registers all registered routers to gin.IRouter using gin.Engine's config.
 func (a *AppRouter) Register() {
+	for _, routers := range a.groups {
+		a.tries[routers[0].method] = newRouteTrie(routers, a.opt.unreachable) // compile all methods' tries first, used to check 405
+	}
 	for idx := range a.groups {
 		routers := a.groups[idx] // same method's routers
 		method := routers[0].method
@@ -232,16 +363,180 @@ func printAppRouteRegister(index, count int, method, relativePath, handlerFuncna
 	fmt.Printf("[XGIN]   %2s %-6s ~/%-23s --> %s (%d handlers) ==> ~/%s\n", pre, method, relativePath, handlerFuncname, handlersCount, layerFakePath)
 }
 
+// ======
+// routes
+// ======
+
+// AppRouteInfo represents a route registered by AppRouter, with the real path rather than the fake path registered to gin.Engine.
+type AppRouteInfo struct {
+	Method        string
+	Path          string          // full path, such as "/v1/users/:id"
+	Handler       string          // name of the last handler, same as gin.RouteInfo
+	HandlersCount int             // count of handlers, including the router's middlewares
+	HandlerFunc   gin.HandlerFunc // the last handler
+}
+
+// Routes returns all the routes of AppRouter with their real paths, in the order of method and registration. Note that gin.Engine's Routes
+// only contains the fake paths like "/v1/:_$1/:_$2", use AppRouter.MergeRoutes to get the merged routes.
+func (a *AppRouter) Routes() []*AppRouteInfo {
+	basePath := a.basePath()
+	middlewaresCount := 0
+	switch r := a.router.(type) {
+	case *gin.Engine:
+		middlewaresCount = len(r.Handlers)
+	case *gin.RouterGroup:
+		middlewaresCount = len(r.Handlers)
+	}
+
+	routes := make([]*AppRouteInfo, 0)
+	for _, routers := range a.groups {
+		for _, router := range routers {
+			handler := router.handlers[len(router.handlers)-1]
+			routes = append(routes, &AppRouteInfo{
+				Method:        router.method,
+				Path:          joinRoutePath(basePath, router.relativePath),
+				Handler:       runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name(),
+				HandlersCount: middlewaresCount + len(router.handlers),
+				HandlerFunc:   handler,
+			})
+		}
+	}
+	return routes
+}
+
+// MergeRoutes replaces the fake routes registered by AppRouter in given gin.RoutesInfo (such as the result of gin.Engine's Routes) with the
+// real ones, the other routes are kept in order and the AppRouter's routes are appended, use nil to get the AppRouter's routes only.
+//
+// Example:
+// 	ap.Register()
+// 	routes := ap.MergeRoutes(app.Routes())
+// 	fmt.Print(xgin.RenderRouteTree(routes))
+func (a *AppRouter) MergeRoutes(routes gin.RoutesInfo) gin.RoutesInfo {
+	appRoutes := a.Routes()
+	registered := make(map[string]bool, len(appRoutes)) // method + " " + path
+	for _, route := range appRoutes {
+		registered[route.Method+" "+route.Path] = true
+	}
+	fakePrefix := joinRoutePath(a.basePath(), ":"+_fakePathPrefix)
+	fakeCatchAllPath := joinRoutePath(a.basePath(), _fakeCatchAllPath)
+
+	merged := make(gin.RoutesInfo, 0, len(routes)+len(appRoutes))
+	for _, route := range routes {
+		// fake routes are registered for every layer count up to the max one, or as a catch-all route, see coreAppRouterRegister
+		if route.Path != fakeCatchAllPath && !strings.HasPrefix(route.Path, fakePrefix) && !registered[route.Method+" "+route.Path] {
+			merged = append(merged, route)
+		}
+	}
+	for _, route := range appRoutes {
+		merged = append(merged, gin.RouteInfo{Method: route.Method, Path: route.Path, Handler: route.Handler, HandlerFunc: route.HandlerFunc})
+	}
+	return merged
+}
+
+// basePath returns the base path of AppRouter's gin.IRouter, such as "/v1".
+func (a *AppRouter) basePath() string {
+	if br, ok := a.router.(interface{ BasePath() string }); ok {
+		return br.BasePath()
+	}
+	return "/"
+}
+
+// joinRoutePath joins given base path and relative path, such as "/v1" and "users" to "/v1/users".
+func joinRoutePath(basePath, relativePath string) string {
+	basePath, relativePath = strings.Trim(basePath, "/"), strings.Trim(relativePath, "/")
+	switch {
+	case basePath == "":
+		return "/" + relativePath
+	case relativePath == "":
+		return "/" + basePath
+	}
+	return "/" + basePath + "/" + relativePath
+}
+
+// routeTreeNode represents a node of the tree rendered by RenderRouteTree.
+type routeTreeNode struct {
+	name     string
+	methods  []string
+	children []*routeTreeNode
+}
+
+// child returns the child node with given name, creates it if not found.
+func (n *routeTreeNode) child(name string) *routeTreeNode {
+	for _, child := range n.children {
+		if child.name == name {
+			return child
+		}
+	}
+	child := &routeTreeNode{name: name}
+	n.children = append(n.children, child)
+	return child
+}
+
+// RenderRouteTree renders given routes as a tree by path segments, each segment is followed by its methods, the segments and methods are
+// sorted in lexicographical order.
+// Renders like:
+// 	/
+// 	└─ v1
+// 	   ├─ login (POST)
+// 	   └─ users (GET, POST)
+// 	      └─ :id (DELETE, GET, PUT)
+// 	         └─ files
+// 	            └─ *path (GET)
+func RenderRouteTree(routes gin.RoutesInfo) string {
+	root := &routeTreeNode{name: "/"}
+	for _, route := range routes {
+		node := root
+		if path := strings.Trim(route.Path, "/"); path != "" {
+			for _, segment := range strings.Split(path, "/") {
+				node = node.child(segment)
+			}
+		}
+		node.methods = append(node.methods, route.Method)
+	}
+
+	sb := &strings.Builder{}
+	writeRouteTreeNode(sb, root, "", "")
+	return sb.String()
+}
+
+// writeRouteTreeNode writes given node and its children to strings.Builder recursively, with given prefixes of the line and the children.
+func writeRouteTreeNode(sb *strings.Builder, node *routeTreeNode, linePrefix, childPrefix string) {
+	sb.WriteString(linePrefix + node.name)
+	if len(node.methods) > 0 {
+		sort.Strings(node.methods)
+		sb.WriteString(" (" + strings.Join(node.methods, ", ") + ")")
+	}
+	sb.WriteString("\n")
+
+	sort.Slice(node.children, func(i, j int) bool { return node.children[i].name < node.children[j].name })
+	for i, child := range node.children {
+		if i == len(node.children)-1 {
+			writeRouteTreeNode(sb, child, childPrefix+"└─ ", childPrefix+"   ")
+		} else {
+			writeRouteTreeNode(sb, child, childPrefix+"├─ ", childPrefix+"│  ")
+		}
+	}
+}
+
 // ====
 // core
 // ====
 
 const (
-	_fakePathPrefix = "_$"
+	_fakePathPrefix   = "_$"
+	_fakeCatchAllPath = "*" + _fakePathPrefix
 )
 
 // coreAppRouterRegister is the core implementation of AppRouter.Register, with given method and routers.
 func coreAppRouterRegister(ar *AppRouter, method string, routers []*routerConfig) {
+	// gin does not support catch-all parameter with other parameters in the same layer, so use one catch-all fake path instead
+	for _, router := range routers {
+		if router.isCatchAll() {
+			coreAppRouterRegisterCatchAll(ar, method, routers)
+			return
+		}
+	}
+
 	// get max layer count
 	maxLayerCount := 0
 	for _, methodRouter := range routers {
@@ -270,14 +565,10 @@ func coreAppRouterRegister(ar *AppRouter, method string, routers []*routerConfig
 		}
 
 		// build layer fake path string
-		layerNumericPaths := make([]string, layer) // :_$1, :_$2, ...
-		for i := 1; i <= layer; i++ {
-			layerNumericPaths[i-1] = ":" + _fakePathPrefix + xnumber.Itoa(i) // <<< :_$
-		}
-		layerFakePath := strings.Join(layerNumericPaths, "/") // :_$1/:_$2/...
+		layerFakePath := buildLayerFakePath(layer) // :_$1/:_$2/...
 
 		// get final handler and register to gin.IRouter
-		targetHandler := buildAppRouterHandler(ar, method, layerRouters, layerFakePath)
+		targetHandler := buildAppRouterHandler(ar, method, layerFakePath)
 		ar.router.Handle(method, layerFakePath, targetHandler)
 
 		// do log after gin's log
@@ -290,22 +581,69 @@ func coreAppRouterRegister(ar *AppRouter, method string, routers []*routerConfig
 	}
 }
 
-// buildAppRouterHandler builds and returns a new gin.HandlerFunc for AppRouter to register to gin.IRouter using given layer routers.
-func buildAppRouterHandler(ar *AppRouter, method string, layerRouters []*routerConfig, layerFakePath string) gin.HandlerFunc {
+// coreAppRouterRegisterCatchAll is the catch-all version of coreAppRouterRegister, all the routers are registered to the fake path "*_$", except
+// the router with empty relative path, which conflicts with catch-all parameter in gin when using root path.
+func coreAppRouterRegisterCatchAll(ar *AppRouter, method string, routers []*routerConfig) {
+	rootPath := strings.Trim(ar.basePath(), "/") == ""
+	catchAllRouters := make([]*routerConfig, 0, len(routers))
+	for _, router := range routers {
+		if len(router.layerNames) == 0 && !rootPath {
+			ar.router.Handle(method, "", router.handlers...) // handle first
+		} else {
+			catchAllRouters = append(catchAllRouters, router)
+		}
+	}
+
+	// get final handler and register to gin.IRouter
+	targetHandler := buildAppRouterHandler(ar, method, _fakeCatchAllPath)
+	ar.router.Handle(method, _fakeCatchAllPath, targetHandler)
+
+	// do log after gin's log
+	if gin.Mode() == gin.DebugMode {
+		for i, router := range catchAllRouters {
+			funcname := runtime.FuncForPC(reflect.ValueOf(router.handlers[0]).Pointer()).Name()
+			printAppRouteRegister(i, len(catchAllRouters), method, router.relativePath, funcname, len(router.handlers), _fakeCatchAllPath)
+		}
+	}
+}
+
+// buildLayerFakePath builds the fake path registered to gin.IRouter for given layer count, such as ":_$1/:_$2" for 2 layers.
+func buildLayerFakePath(layer int) string {
+	layerNumericPaths := make([]string, layer) // :_$1, :_$2, ...
+	for i := 1; i <= layer; i++ {
+		layerNumericPaths[i-1] = ":" + _fakePathPrefix + xnumber.Itoa(i) // <<< :_$
+	}
+	return strings.Join(layerNumericPaths, "/")
+}
+
+// buildAppRouterHandler builds and returns a new gin.HandlerFunc for AppRouter to register to gin.IRouter using the compiled trie of given method.
+func buildAppRouterHandler(ar *AppRouter, method string, layerFakePath string) gin.HandlerFunc {
 	// will be invoked at runtime
 	return func(c *gin.Context) {
-		// find accepted handlers ==> O(avg_#routers * avg_#layers)
-		handlers, ok := findAppRouterHandlers(c, layerRouters, layerFakePath, true)
+		// find accepted router ==> O(avg_#layers) for most cases, see routeTrieNode.match
+		layerValues := getLayerValues(c)
+		router, ok := ar.tries[method].match(layerValues)
 
 		// handlers not found, use 404 or 405 (note that this may be handled by gin)
-		if !ok {
+		var handlers []gin.HandlerFunc
+		if ok {
+			setAppRouterContext(c, router, layerValues, layerFakePath)
+			handlers = router.handlers
+		} else {
+			// keep gin's trailing slash redirection, which is lost for the layer values matched by fake path (especially "*_$")
+			if ar.engine.RedirectTrailingSlash && method != "CONNECT" && len(layerValues) > 0 {
+				if _, ok := ar.tries[method].match(toggleTrailingSlash(layerValues)); ok {
+					redirectTrailingSlash(c)
+					return
+				}
+			}
 			handlers = ar.noRouter // use 404 noRouter
 			if ar.engine.HandleMethodNotAllowed {
-				for _, methodRouters := range ar.groups {
-					if method == methodRouters[0].method {
+				for otherMethod, trie := range ar.tries {
+					if method == otherMethod {
 						continue
 					}
-					if _, ok := findAppRouterHandlers(c, methodRouters, layerFakePath, false); ok {
+					if _, ok := trie.match(layerValues); ok {
 						handlers = ar.noMethod // use 405 noMethod
 						break
 					}
@@ -322,60 +660,179 @@ func buildAppRouterHandler(ar *AppRouter, method string, layerRouters []*routerC
 	}
 }
 
-// findAppRouterHandlers finds a acceptable []gin.HandlerFunc from routers by given gin.Context (with its parameters) and switcher for changing gin.Context's parameter.
-func findAppRouterHandlers(c *gin.Context, routers []*routerConfig, layerFakePath string, changeContext bool) ([]gin.HandlerFunc, bool) {
+// toggleTrailingSlash returns the layer values with trailing slash removed if it ends with empty value, otherwise with trailing slash added.
+func toggleTrailingSlash(layerValues []string) []string {
+	if n := len(layerValues); n > 0 && layerValues[n-1] == "" {
+		return layerValues[:n-1]
+	}
+	return append(layerValues[:len(layerValues):len(layerValues)], "")
+}
+
+// redirectTrailingSlash redirects the request to the path with trailing slash toggled, this is the same as gin's unexported function.
+func redirectTrailingSlash(c *gin.Context) {
+	req := c.Request
+	p := req.URL.Path
+	if prefix := path.Clean(req.Header.Get("X-Forwarded-Prefix")); prefix != "." {
+		p = prefix + "/" + req.URL.Path
+	}
+	req.URL.Path = p + "/"
+	if length := len(p); length > 1 && p[length-1] == '/' {
+		req.URL.Path = p[:length-1]
+	}
+
+	code := http.StatusMovedPermanently // permanent redirect, request with GET method
+	if req.Method != http.MethodGet {
+		code = http.StatusTemporaryRedirect
+	}
+	http.Redirect(c.Writer, req, req.URL.String(), code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
+}
+
+// getLayerValues returns the actual layer values from gin.Context's parameters, which are named as ":_$1", ":_$2" and so on, or split
+// from the catch-all parameter "*_$".
+func getLayerValues(c *gin.Context) []string {
+	if value, ok := c.Params.Get(_fakeCatchAllPath[1:]); ok {
+		if value = strings.TrimPrefix(value, "/"); value == "" {
+			return []string{}
+		}
+		return strings.Split(value, "/")
+	}
+
+	layerCount := 0 // start with _$'s layer name's count
+	for _, param := range c.Params {
+		if strings.HasPrefix(param.Key, _fakePathPrefix) {
+			layerCount++
+		}
+	}
+	values := make([]string, layerCount)
+	for i := range values {
+		values[i] = c.Param(_fakePathPrefix + xnumber.Itoa(i+1))
+	}
+	return values
+}
+
+// setAppRouterContext sets the parameters and full path of gin.Context for the accepted router.
+func setAppRouterContext(c *gin.Context, router *routerConfig, layerValues []string, layerFakePath string) {
+	// set new c.Params
+	for idx, layerName := range router.layerNames {
+		if strings.HasPrefix(layerName, ":") { // is a parameter
+			key, _ := splitParamLayer(layerName) // :bbb<int> ===> :_$2
+			c.Params = append(c.Params, gin.Param{Key: key, Value: layerValues[idx]})
+		} else if strings.HasPrefix(layerName, "*") { // is a catch-all parameter, the value starts with "/" just like gin
+			key, _ := splitParamLayer(layerName) // *ccc ===> *_$
+			c.Params = append(c.Params, gin.Param{Key: key, Value: "/" + strings.Join(layerValues[idx:], "/")})
+		}
+	}
+
+	// set new c.fullPath
+	fullPath := strings.TrimSuffix(c.FullPath(), "/")
+	fullPath = strings.TrimSuffix(strings.TrimSuffix(fullPath, layerFakePath), "/")
+	fullPath = fmt.Sprintf("%s/%s", fullPath, router.relativePath)
+	xreflect.SetUnexportedField(reflect.ValueOf(c).Elem().FieldByName("fullPath"), fullPath) // /xxx/:_$1/:_$2/:_$3 ===> /xxx/aaa/:bbb/ccc
+}
+
+// routeTrieNode represents a node of the trie compiled from the same method's routers in AppRouter.Register, each layer of the relative
+// path is a node, the parameter layers of all the routers share the same param child, and the catch-all router is stored in the node before
+// its last layer. For example:
+// 	a/b, a/:y, :x/b => root --a--> node --b--> (a/b)
+// 	                    |             \--:--> (a/:y)
+// 	                    \--:--> node --b--> (:x/b)
+type routeTrieNode struct {
+	statics     map[string]*routeTrieNode
+	constrained []*routeTrieNode // param children with constraints, in registration order
+	param       *routeTrieNode   // param child without constraint
+	constraint  *paramConstraint // constraint of this node if it is a constrained param child
+	router      *routerConfig    // the router ends at this node
+	catchAll    *routerConfig    // the router ends with catch-all parameter after this node
+}
+
+// newRouteTrie compiles given routers to a trie, and returns its root node. The router which has the same layers as the existing router
+// (such as "a/:y" and "a/:x") can never be reached, it will be passed to given unreachable function if it is not nil.
+func newRouteTrie(routers []*routerConfig, unreachable func(router, existed *routerConfig)) *routeTrieNode {
+	root := &routeTrieNode{}
 	for _, router := range routers {
-		// filter different length of path layers
-		actualLayerCount := 0 // start with _$'s layer name's count
-		for _, param := range c.Params {
-			if strings.HasPrefix(param.Key, _fakePathPrefix) {
-				actualLayerCount++
+		node := root
+		if router.isCatchAll() {
+			for i := 0; i < len(router.layerNames)-1; i++ {
+				node = node.child(router.layerNames[i], router.constraints[i])
+			}
+			if node.catchAll == nil {
+				node.catchAll = router
+			} else if unreachable != nil {
+				unreachable(router, node.catchAll)
 			}
-		}
-		if actualLayerCount != len(router.layerNames) {
 			continue
 		}
+		for i, layerName := range router.layerNames {
+			node = node.child(layerName, router.constraints[i])
+		}
+		if node.router == nil {
+			node.router = router
+		} else if unreachable != nil {
+			unreachable(router, node.router)
+		}
+	}
+	return root
+}
 
-		// check if accept the current router
-		accept := true
-		for idx, layerName := range router.layerNames {
-			// layerNames: aaa, :bbb, ccc
-			// fullPath: /xxx/:_$1/:_$2/:_$3
-			if strings.HasPrefix(layerName, ":") { // start with `:`, is a parameter, need to be converted
-				continue
-			}
-			layerKey := _fakePathPrefix + xnumber.Itoa(idx+1)
-			if layerName != c.Param(layerKey) {
-				accept = false // the actual layer name does not equal to the given router's layer name
-				break
+// child returns the static or param child node of given layer name and constraint, creates it if not found.
+func (n *routeTrieNode) child(layerName string, constraint *paramConstraint) *routeTrieNode {
+	if constraint != nil {
+		for _, child := range n.constrained {
+			if child.constraint.source == constraint.source {
+				return child
 			}
 		}
+		child := &routeTrieNode{constraint: constraint}
+		n.constrained = append(n.constrained, child)
+		return child
+	}
+	if strings.HasPrefix(layerName, ":") {
+		if n.param == nil {
+			n.param = &routeTrieNode{}
+		}
+		return n.param
+	}
+	if n.statics == nil {
+		n.statics = make(map[string]*routeTrieNode)
+	}
+	child, ok := n.statics[layerName]
+	if !ok {
+		child = &routeTrieNode{}
+		n.statics[layerName] = child
+	}
+	return child
+}
 
-		// change context and return if accept
-		if accept {
-			if changeContext {
-				// set new c.Params
-				for idx, layerName := range router.layerNames {
-					if strings.HasPrefix(layerName, ":") { // is a parameter
-						key := layerName[1:]                              // :bbb ===> :_$2
-						layerKey := _fakePathPrefix + xnumber.Itoa(idx+1) // :_$2
-						value := c.Param(layerKey)
-						c.Params = append(c.Params, gin.Param{Key: key, Value: value})
-					}
+// match finds the router accepting given layer values, static layer takes precedence over parameter layer with constraint (checked in the
+// registration order), then parameter layer without constraint, then catch-all parameter, and layers are checked from left to right, so
+// the result does not depend on the registration order except for the constraints. Note that this method is O(#layers) if
+// there is no static and param child in the same node, otherwise the param child will be searched when the static one does not match. Also
+// note that parameter does not accept empty value, but catch-all parameter does, such as "/files/" for "files/*path".
+func (n *routeTrieNode) match(layerValues []string) (*routerConfig, bool) {
+	if n == nil {
+		return nil, false
+	}
+	if len(layerValues) == 0 {
+		return n.router, n.router != nil
+	}
+	if child, ok := n.statics[layerValues[0]]; ok {
+		if router, ok := child.match(layerValues[1:]); ok {
+			return router, true
+		}
+	}
+	if layerValues[0] != "" {
+		for _, child := range n.constrained {
+			if child.constraint.match(layerValues[0]) {
+				if router, ok := child.match(layerValues[1:]); ok {
+					return router, true
 				}
-
-				// set new c.fullPath
-				fullPath := strings.TrimSuffix(c.FullPath(), "/")
-				fullPath = strings.TrimSuffix(strings.TrimSuffix(fullPath, layerFakePath), "/")
-				fullPath = fmt.Sprintf("%s/%s", fullPath, router.relativePath)
-				xreflect.SetUnexportedField(reflect.ValueOf(c).Elem().FieldByName("fullPath"), fullPath) // /xxx/:_$1/:_$2/:_$3 ===> /xxx/aaa/:bbb/ccc
 			}
-
-			// return the accepted router
-			return router.handlers, true
+		}
+		if router, ok := n.param.match(layerValues[1:]); ok {
+			return router, true
 		}
 	}
-
-	// not found
-	return nil, false
+	return n.catchAll, n.catchAll != nil
 }
@@ -3,10 +3,13 @@ package xgin
 import (
 	"context"
 	"fmt"
+	"github.com/Aoi-hosizora/ahlib/xnumber"
 	"github.com/Aoi-hosizora/ahlib/xtesting"
 	"github.com/gin-gonic/gin"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -250,3 +253,496 @@ func TestAppRouterFunction(t *testing.T) {
 		}
 	}
 }
+
+func TestAppRouterRoutes(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.GET("ping", func(*gin.Context) {})
+	mw := func(*gin.Context) {}
+	fn := func(*gin.Context) {}
+	ar := NewAppRouter(app, app.Group("v1", mw))
+	ar.GET("", fn)
+	ar.GET("users", fn)
+	ar.POST("users", mw, fn)
+	ar.GET("users/:id", fn)
+	ar.GET("users/me", fn)
+	ar.DELETE("users/:id", fn)
+	ar.GET("users/:id/files/*path", fn)
+	ar.Register()
+
+	fnName := "github.com/Aoi-hosizora/ahlib-web/xgin.TestAppRouterRoutes.func3"
+	routes := ar.Routes()
+	xtesting.Equal(t, len(routes), 7)
+	for i, want := range []*AppRouteInfo{
+		{Method: "GET", Path: "/v1", Handler: fnName, HandlersCount: 2},
+		{Method: "GET", Path: "/v1/users", Handler: fnName, HandlersCount: 2},
+		{Method: "GET", Path: "/v1/users/:id", Handler: fnName, HandlersCount: 2},
+		{Method: "GET", Path: "/v1/users/me", Handler: fnName, HandlersCount: 2},
+		{Method: "GET", Path: "/v1/users/:id/files/*path", Handler: fnName, HandlersCount: 2},
+		{Method: "POST", Path: "/v1/users", Handler: fnName, HandlersCount: 3},
+		{Method: "DELETE", Path: "/v1/users/:id", Handler: fnName, HandlersCount: 2},
+	} {
+		if i < len(routes) {
+			xtesting.NotNil(t, routes[i].HandlerFunc)
+			routes[i].HandlerFunc = nil
+			xtesting.Equal(t, routes[i], want)
+		}
+	}
+
+	// merge
+	paths := make([]string, 0)
+	for _, route := range ar.MergeRoutes(app.Routes()) {
+		paths = append(paths, route.Method+" "+route.Path)
+	}
+	xtesting.Equal(t, paths, []string{
+		"GET /ping",
+		"GET /v1",
+		"GET /v1/users",
+		"GET /v1/users/:id",
+		"GET /v1/users/me",
+		"GET /v1/users/:id/files/*path",
+		"POST /v1/users",
+		"DELETE /v1/users/:id",
+	})
+	xtesting.Equal(t, len(NewAppRouter(app, app).MergeRoutes(nil)), 0)
+
+	// tree
+	xtesting.Equal(t, RenderRouteTree(ar.MergeRoutes(app.Routes())), `/
+├─ ping (GET)
+└─ v1 (GET)
+   └─ users (GET, POST)
+      ├─ :id (DELETE, GET)
+      │  └─ files
+      │     └─ *path (GET)
+      └─ me (GET)
+`)
+	xtesting.Equal(t, RenderRouteTree(nil), "/\n")
+	xtesting.Equal(t, RenderRouteTree(gin.RoutesInfo{{Method: "GET", Path: "/"}}), "/ (GET)\n")
+	xtesting.Equal(t, joinRoutePath("/", ""), "/")
+	xtesting.Equal(t, joinRoutePath("", "/a/"), "/a")
+}
+
+func TestAppRouterTrie(t *testing.T) {
+	routers := make([]*routerConfig, 0)
+	for _, path := range []string{
+		"", "a", ":x", "a/b", ":x/b", "a/:y", ":x/:y",
+		"a/b/c", "a/b/:z", "a/:y/c", ":x/b/c", "a/:y/:z", ":x/b/:z", ":x/:y/c", ":x/:y/:z",
+		"a/b/c/d", "b/:y/c/d", "b/x/:z/d", "a/b", "c/:y", ":x/x/:z/d",
+	} {
+		routers = append(routers, newRouterConfig(http.MethodGet, path, func(*gin.Context) {}))
+	}
+	unreachable := make([]string, 0)
+	trie := newRouteTrie(routers, func(router, existed *routerConfig) {
+		unreachable = append(unreachable, router.relativePath+" "+existed.relativePath)
+	})
+	xtesting.Equal(t, unreachable, []string{"a/b a/b"})
+
+	for _, tc := range []struct {
+		give []string
+		want string
+	}{
+		{[]string{}, ""},
+		{[]string{"a"}, "a"},
+		{[]string{"b"}, ":x"},
+		{[]string{"a", "b"}, "a/b"},
+		{[]string{"a", "x"}, "a/:y"},
+		{[]string{"x", "b"}, ":x/b"},
+		{[]string{"x", "y"}, ":x/:y"},
+		{[]string{"a", "b", "c"}, "a/b/c"},
+		{[]string{"a", "b", "x"}, "a/b/:z"},
+		{[]string{"a", "x", "c"}, "a/:y/c"},
+		{[]string{"x", "b", "c"}, ":x/b/c"},
+		{[]string{"a", "x", "y"}, "a/:y/:z"},
+		{[]string{"x", "b", "y"}, ":x/b/:z"},
+		{[]string{"x", "y", "c"}, ":x/:y/c"},
+		{[]string{"x", "y", "z"}, ":x/:y/:z"},
+		{[]string{"a", "b", "c", "d"}, "a/b/c/d"},
+		{[]string{"c", "y"}, "c/:y"},
+		{[]string{"c", "b"}, "c/:y"},
+		{[]string{"b", "x", "c", "d"}, "b/x/:z/d"},
+		{[]string{"b", "y", "c", "d"}, "b/:y/c/d"},
+		{[]string{"c", "x", "x", "d"}, ":x/x/:z/d"},
+	} {
+		router, ok := trie.match(tc.give)
+		xtesting.True(t, ok)
+		if ok {
+			xtesting.Equal(t, router.relativePath, tc.want)
+		}
+	}
+	for _, give := range [][]string{{"a", "b", "c", "e"}, {"b", "y", "c", "e"}, {"a", "b", "c", "d", "e"}, {"a", ""}, {"", "b"}} {
+		_, ok := trie.match(give)
+		xtesting.False(t, ok)
+	}
+	_, ok := (*routeTrieNode)(nil).match([]string{"a"})
+	xtesting.False(t, ok)
+
+	// context
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.HandleMethodNotAllowed = true
+	warnings := make([]string, 0)
+	ar := NewAppRouter(app, app.Group("v1"), WithUnreachableRouteWarning(func(method, relativePath, existedPath string) {
+		warnings = append(warnings, fmt.Sprintf("%s %s %s", method, relativePath, existedPath))
+	}))
+	fn := func(c *gin.Context) {
+		c.String(200, "%s %s %s %s", c.FullPath(), c.Param("x"), c.Param("y"), c.Param("z"))
+	}
+	ar.GET(":x/b", fn)
+	ar.GET("a/:y", fn)
+	ar.GET(":x/:y/:z", fn)
+	ar.GET("a/:z", fn)
+	ar.POST("a/b/c/d", fn)
+	ar.POST("a/b/c/d", fn)
+	ar.Register()
+	xtesting.Equal(t, warnings, []string{"GET a/:z a/:y", "POST a/b/c/d a/b/c/d"})
+	ar2 := NewAppRouter(app, app.Group("v2"), WithUnreachableRouteWarning(nil), nil)
+	ar2.GET(":x/a", fn)
+	ar2.GET(":y/a", fn) // [XGIN-warning] GET ~/:y/a is unreachable, because it is shadowed by ~/:x/a
+	ar2.Register()
+	for _, tc := range []struct {
+		giveMethod string
+		givePath   string
+		wantCode   int
+		wantBody   string
+	}{
+		{http.MethodGet, "/v1/a/b", 200, "/v1/a/:y  b "},
+		{http.MethodGet, "/v1/x/b", 200, "/v1/:x/b x  "},
+		{http.MethodGet, "/v1/x/y", 404, "404 page not found"},
+		{http.MethodGet, "/v1/x/y/z", 200, "/v1/:x/:y/:z x y z"},
+		{http.MethodGet, "/v1/a/b/c/d", 405, "405 method not allowed"},
+		{http.MethodPost, "/v1/a/b/c/d", 200, "/v1/a/b/c/d   "},
+		{http.MethodPost, "/v1/a/b/c/e", 404, "404 page not found"},
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(tc.giveMethod, tc.givePath, nil))
+		xtesting.Equal(t, w.Code, tc.wantCode)
+		xtesting.Equal(t, w.Body.String(), tc.wantBody)
+	}
+}
+
+// linearFindAppRouter is the linear scan used by AppRouter before compiling routers to a trie (see the original findAppRouterHandlers), which
+// returns the first router whose layer count and static layers match the gin.Context's parameters, and is kept as the benchmark baseline.
+func linearFindAppRouter(c *gin.Context, routers []*routerConfig) (*routerConfig, bool) {
+	for _, router := range routers {
+		// filter different length of path layers
+		actualLayerCount := 0 // start with _$'s layer name's count
+		for _, param := range c.Params {
+			if strings.HasPrefix(param.Key, _fakePathPrefix) {
+				actualLayerCount++
+			}
+		}
+		if actualLayerCount != len(router.layerNames) {
+			continue
+		}
+
+		// check if accept the current router
+		accept := true
+		for idx, layerName := range router.layerNames {
+			if strings.HasPrefix(layerName, ":") { // start with `:`, is a parameter
+				continue
+			}
+			layerKey := _fakePathPrefix + xnumber.Itoa(idx+1)
+			if layerName != c.Param(layerKey) {
+				accept = false // the actual layer name does not equal to the given router's layer name
+				break
+			}
+		}
+		if accept {
+			return router, true
+		}
+	}
+	return nil, false
+}
+
+// benchmarkAppRouterPaths are the routers and requests used by the AppRouter benchmarks.
+var (
+	benchmarkAppRouterPaths = func() []string {
+		paths := make([]string, 0, 501)
+		for i := 0; i < 500; i++ {
+			paths = append(paths, fmt.Sprintf("resource%d/:id/items", i))
+		}
+		return append(paths, ":kind/:id/:sub")
+	}()
+	benchmarkAppRouterRequests = []struct {
+		name        string
+		layerValues []string
+		want        string
+	}{
+		{"first", []string{"resource0", "1", "items"}, "resource0/:id/items"},
+		{"last", []string{"resource499", "1", "items"}, "resource499/:id/items"},
+		{"param", []string{"x", "1", "y"}, ":kind/:id/:sub"},
+	}
+)
+
+func BenchmarkAppRouterMatch(b *testing.B) {
+	routers := make([]*routerConfig, 0, len(benchmarkAppRouterPaths))
+	for _, path := range benchmarkAppRouterPaths {
+		routers = append(routers, newRouterConfig(http.MethodGet, path, func(*gin.Context) {}))
+	}
+	trie := newRouteTrie(routers, nil)
+
+	for _, tc := range benchmarkAppRouterRequests {
+		c := &gin.Context{}
+		for idx, value := range tc.layerValues {
+			c.Params = append(c.Params, gin.Param{Key: _fakePathPrefix + xnumber.Itoa(idx+1), Value: value})
+		}
+		for _, find := range []func() (*routerConfig, bool){
+			func() (*routerConfig, bool) { return linearFindAppRouter(c, routers) },
+			func() (*routerConfig, bool) { return trie.match(getLayerValues(c)) },
+		} {
+			if router, ok := find(); !ok || router.relativePath != tc.want {
+				b.Fatalf("unexpected router for %v", tc.layerValues)
+			}
+		}
+
+		b.Run("linear_"+tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linearFindAppRouter(c, routers)
+			}
+		})
+		b.Run("trie_"+tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				trie.match(getLayerValues(c))
+			}
+		})
+	}
+}
+
+func BenchmarkAppRouter(b *testing.B) {
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	ar := NewAppRouter(app, app.Group("v1"))
+	for _, path := range benchmarkAppRouterPaths {
+		ar.GET(path, func(*gin.Context) {})
+	}
+	ar.Register()
+
+	for _, tc := range benchmarkAppRouterRequests {
+		b.Run(tc.name, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/"+strings.Join(tc.layerValues, "/"), nil)
+			w := httptest.NewRecorder()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				app.ServeHTTP(w, req)
+			}
+		})
+	}
+}
+
+func TestAppRouterCatchAll(t *testing.T) {
+	for _, path := range []string{"*", "*a/b", "a/*b/c"} {
+		xtesting.Panic(t, func() { newRouterConfig(http.MethodGet, path, func(*gin.Context) {}) })
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	fn := func(c *gin.Context) {
+		c.String(200, "%s|%s|%s", c.FullPath(), c.Param("id"), c.Param("path"))
+	}
+	for _, base := range []string{"", "v1"} {
+		app := gin.New()
+		app.HandleMethodNotAllowed = true
+		warnings := make([]string, 0)
+		ar := NewAppRouter(app, app.Group(base), WithUnreachableRouteWarning(func(method, relativePath, existedPath string) {
+			warnings = append(warnings, relativePath+" "+existedPath)
+		}))
+		ar.GET("", fn)
+		ar.GET("files/*path", fn)
+		ar.GET("files/meta", fn)
+		ar.GET("files/:id", fn)
+		ar.GET("files/:id/raw", fn)
+		ar.GET(":id/*path", fn)
+		ar.GET("files/*other", fn)
+		ar.POST("files/:id", fn)
+		ar.Register()
+		xtesting.Equal(t, warnings, []string{"files/*other files/*path"})
+
+		prefix := "/" + base
+		if base != "" {
+			prefix += "/"
+		}
+		for _, tc := range []struct {
+			giveMethod string
+			givePath   string
+			wantCode   int
+			wantBody   string
+		}{
+			{http.MethodGet, "", 200, "%s||"},
+			{http.MethodGet, "files/meta", 200, "%sfiles/meta||"},
+			{http.MethodGet, "files/1", 200, "%sfiles/:id|1|"},
+			{http.MethodGet, "files/1/raw", 200, "%sfiles/:id/raw|1|"},
+			{http.MethodGet, "files/1/raw/x", 200, "%sfiles/*path||/1/raw/x"},
+			{http.MethodGet, "files/meta/x", 200, "%sfiles/*path||/meta/x"},
+			{http.MethodGet, "files/", 200, "%sfiles/*path||/"},
+			{http.MethodGet, "other/a/b/", 200, "%s:id/*path|other|/a/b/"},
+			{http.MethodGet, "other", 301, "<a href=\"%sother/\">Moved Permanently</a>.\n\n"},
+			{http.MethodPost, "files/1", 200, "%sfiles/:id|1|"},
+			{http.MethodPost, "files/1/x", 405, "405 method not allowed"},
+			{http.MethodPost, "files", 404, "404 page not found"},
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(tc.giveMethod, prefix+tc.givePath, nil))
+			xtesting.Equal(t, w.Code, tc.wantCode)
+			if w.Code == 200 || w.Code == 301 {
+				xtesting.Equal(t, w.Body.String(), fmt.Sprintf(tc.wantBody, prefix))
+			} else {
+				xtesting.Equal(t, w.Body.String(), tc.wantBody)
+			}
+		}
+
+		paths := make([]string, 0)
+		for _, route := range ar.MergeRoutes(app.Routes()) {
+			if route.Method == http.MethodGet {
+				paths = append(paths, route.Path)
+			}
+		}
+		xtesting.Equal(t, paths, []string{
+			"/" + base, prefix + "files/*path", prefix + "files/meta", prefix + "files/:id", prefix + "files/:id/raw", prefix + ":id/*path", prefix + "files/*other",
+		})
+	}
+
+	// trailing slash redirection, which should be the same as gin
+	for _, redirect := range []bool{true, false} {
+		ginApp, app := gin.New(), gin.New()
+		ginApp.RedirectTrailingSlash, app.RedirectTrailingSlash = redirect, redirect
+		ginApp.GET("/v1/users/:id", fn)
+		ginApp.POST("/v1/users/:id", fn)
+		ginApp.GET("/v2/files/*path", fn)
+		ar := NewAppRouter(app, app.Group("v1"))
+		ar.GET("users/:id", fn)
+		ar.POST("users/:id", fn)
+		ar.GET("static/*path", fn)
+		ar.Register()
+		ar = NewAppRouter(app, app.Group("v2"))
+		ar.GET("files/*path", fn)
+		ar.Register()
+
+		for _, tc := range []struct {
+			giveMethod   string
+			givePath     string
+			wantCode     int
+			wantLocation string
+		}{
+			{http.MethodGet, "/v1/users/1/", 301, "/v1/users/1"},
+			{http.MethodPost, "/v1/users/1/", 307, "/v1/users/1"},
+			{http.MethodGet, "/v1/users/1/x", 404, ""},
+			{http.MethodGet, "/v2/files", 301, "/v2/files/"},
+			{http.MethodGet, "/v2/files/", 200, ""},
+		} {
+			for _, engine := range []*gin.Engine{ginApp, app} {
+				w := httptest.NewRecorder()
+				engine.ServeHTTP(w, httptest.NewRequest(tc.giveMethod, tc.givePath, nil))
+				if !redirect && tc.wantLocation != "" {
+					xtesting.Equal(t, w.Code, 404)
+					continue
+				}
+				xtesting.Equal(t, w.Code, tc.wantCode)
+				xtesting.Equal(t, w.Header().Get("Location"), tc.wantLocation)
+			}
+		}
+	}
+}
+
+func TestAppRouterConstraint(t *testing.T) {
+	for _, path := range []string{":id<[a-z>", "a/:id<(>"} {
+		xtesting.Panic(t, func() { newRouterConfig(http.MethodGet, path, func(*gin.Context) {}) })
+	}
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	fn := func(*gin.Context) {}
+	xtesting.Panic(t, func() {
+		ar := NewAppRouter(app, app)
+		ar.GET(":a<int>", fn)
+		ar.GET(":b<int>", fn)
+	})
+	xtesting.Panic(t, func() {
+		ar := NewAppRouter(app, app)
+		ar.GET(":a<int>/:b", fn)
+		ar.GET(":c<int>/:d", fn)
+	})
+	xtesting.NotPanic(t, func() {
+		ar := NewAppRouter(app, app)
+		ar.GET(":a<int>", fn)
+		ar.GET(":b<uuid>", fn)
+		ar.GET(":c", fn)
+		ar.GET(":a<int>/:b", fn)
+		ar.GET(":c/:d<int>", fn)
+	})
+
+	for _, tc := range []struct {
+		give     string
+		wantName string
+		wantCons string
+	}{
+		{":id", "id", ""},
+		{":id<int>", "id", "int"},
+		{":id<[0-9]{2}>", "id", "[0-9]{2}"},
+		{":id<int", "id<int", ""},
+		{"*path", "path", ""},
+	} {
+		name, constraint := splitParamLayer(tc.give)
+		xtesting.Equal(t, name, tc.wantName)
+		xtesting.Equal(t, constraint, tc.wantCons)
+	}
+
+	app = gin.New()
+	warnings := make([]string, 0)
+	ar := NewAppRouter(app, app.Group("v1"), WithUnreachableRouteWarning(func(method, relativePath, existedPath string) {
+		warnings = append(warnings, relativePath+" "+existedPath)
+	}))
+	handler := func(name string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.String(200, "%s %s %s", name, c.FullPath(), c.Params.ByName(name))
+		}
+	}
+	ar.GET("users/:slug", handler("slug"))
+	ar.GET("users/:id<int>", handler("id"))
+	ar.GET("users/:code<[a-z]{3}>", handler("code"))
+	ar.GET("users/:uid<uuid>/files", handler("uid"))
+	ar.GET("users/:day<date>", handler("day"))
+	ar.GET("users/new", handler("new"))
+	ar.GET("users/:x<[a-z]{3}>/:y", handler("x"))
+	ar.GET("users/:code2<[a-z]{3}>", handler("code2"))
+	ar.Register()
+	xtesting.Equal(t, warnings, []string{"users/:code2<[a-z]{3}> users/:code<[a-z]{3}>"})
+
+	for _, tc := range []struct {
+		givePath string
+		wantCode int
+		wantBody string
+	}{
+		{"/v1/users/1", 200, "id /v1/users/:id<int> 1"},
+		{"/v1/users/-12", 200, "id /v1/users/:id<int> -12"},
+		{"/v1/users/abc", 200, "code /v1/users/:code<[a-z]{3}> abc"},
+		{"/v1/users/abcd", 200, "slug /v1/users/:slug abcd"},
+		{"/v1/users/new", 200, "new /v1/users/new "},
+		{"/v1/users/2021-01-02", 200, "day /v1/users/:day<date> 2021-01-02"},
+		{"/v1/users/2021-13-02", 200, "slug /v1/users/:slug 2021-13-02"},
+		{"/v1/users/123e4567-e89b-12d3-a456-426614174000/files", 200, "uid /v1/users/:uid<uuid>/files 123e4567-e89b-12d3-a456-426614174000"},
+		{"/v1/users/abc/files", 200, "x /v1/users/:x<[a-z]{3}>/:y abc"},
+		{"/v1/users/abcd/files", 404, "404 page not found"},
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.givePath, nil))
+		xtesting.Equal(t, w.Code, tc.wantCode)
+		xtesting.Equal(t, w.Body.String(), tc.wantBody)
+	}
+
+	// openapi
+	doc := ar.GenerateOpenAPI(nil)
+	for _, tc := range []struct {
+		givePath   string
+		wantSchema *JSONSchema
+	}{
+		{"/v1/users/{slug}", &JSONSchema{Type: "string"}},
+		{"/v1/users/{id}", &JSONSchema{Type: "integer"}},
+		{"/v1/users/{code}", &JSONSchema{Type: "string", Pattern: "^(?:[a-z]{3})$"}},
+		{"/v1/users/{uid}/files", &JSONSchema{Type: "string", Format: "uuid"}},
+		{"/v1/users/{day}", &JSONSchema{Type: "string", Format: "date"}},
+	} {
+		op := doc.Paths[tc.givePath]["get"]
+		xtesting.NotNil(t, op)
+		if op != nil {
+			xtesting.Equal(t, op.Parameters[0].Schema, tc.wantSchema)
+		}
+	}
+}
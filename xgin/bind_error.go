@@ -0,0 +1,152 @@
+package xgin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// BindErrorKind represents the kind of BindError, which tells a validation failure apart from a request body syntax error or a type mismatch.
+type BindErrorKind string
+
+const (
+	BindErrorValidation   BindErrorKind = "validation"    // validator.ValidationErrors, Fields will be set
+	BindErrorSyntax       BindErrorKind = "syntax"        // json.SyntaxError, empty body or unexpected EOF
+	BindErrorTypeMismatch BindErrorKind = "type_mismatch" // json.UnmarshalTypeError or strconv.NumError, Fields may be set
+	BindErrorUnknown      BindErrorKind = "unknown"       // other errors
+)
+
+// BindError represents a structured bind error parsed from the error returned by gin.Context's Bind methods, see ParseBindError.
+type BindError struct {
+	Kind    BindErrorKind `json:"kind"`
+	Message string        `json:"message"`
+	Fields  []*FieldError `json:"fields,omitempty"`
+	Err     error         `json:"-"` // the original error
+}
+
+// FieldError represents a field error in BindError.
+type FieldError struct {
+	Field   string `json:"field"`   // field path from struct tags, such as "user.emails[0]"
+	Tag     string `json:"tag"`     // failed validation tag, such as "required", empty for type mismatch
+	Param   string `json:"param"`   // validation tag's param, such as "10" in "max=10"
+	Message string `json:"message"` // translated message if translator is given
+}
+
+// Error returns the error message of the BindError.
+func (b *BindError) Error() string {
+	return b.Message
+}
+
+// Unwrap returns the original error of the BindError.
+func (b *BindError) Unwrap() error {
+	return b.Err
+}
+
+// bindFieldTags represents the struct tags used to generate field path, in priority order.
+var bindFieldTags = []string{"json", "form", "uri", "header"}
+
+// ParseBindError parses the error returned by gin.Context's Bind methods to a structured BindError, using given binding object and optional
+// ut.Translator. The field paths are generated from the `json`, `form`, `uri` or `header` tags (in priority order) of given object's type,
+// including nested fields and slice indices, such as "user.emails[0]", and the struct field name will be used if there is no tag. Note that
+// the messages will not be translated if translator is nil, and nil will be returned if err is nil.
+//
+// Example:
+// 	req := &CreateUserRequest{}
+// 	if err := c.ShouldBindJSON(req); err != nil {
+// 		bindErr := xgin.ParseBindError(err, req, translator)
+// 		c.JSON(400, bindErr) // {"kind": "validation", "message": "...", "fields": [{"field": "user.emails[0]", "tag": "email", ...}]}
+// 	}
+func ParseBindError(err error, obj interface{}, translator ut.Translator) *BindError {
+	if err == nil {
+		return nil
+	}
+
+	var ve validator.ValidationErrors
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	var ne *strconv.NumError
+	switch {
+	case errors.As(err, &ve):
+		typ := reflect.TypeOf(obj)
+		fields := make([]*FieldError, 0, len(ve))
+		messages := make([]string, 0, len(ve))
+		for _, fe := range ve {
+			message := fe.Translate(translator) // fallback to fe.Error() if translator is nil or no translation is registered
+			fields = append(fields, &FieldError{Field: fieldPathByTags(typ, fe.StructNamespace()), Tag: fe.Tag(), Param: fe.Param(), Message: message})
+			messages = append(messages, message)
+		}
+		return &BindError{Kind: BindErrorValidation, Message: strings.Join(messages, "; "), Fields: fields, Err: err}
+	case errors.As(err, &se), err == io.EOF, err == io.ErrUnexpectedEOF:
+		return &BindError{Kind: BindErrorSyntax, Message: err.Error(), Err: err}
+	case errors.As(err, &te):
+		message := err.Error()
+		fields := []*FieldError{{Field: te.Field, Message: message}}
+		if te.Field == "" {
+			fields = nil
+		}
+		return &BindError{Kind: BindErrorTypeMismatch, Message: message, Fields: fields, Err: err}
+	case errors.As(err, &ne):
+		return &BindError{Kind: BindErrorTypeMismatch, Message: err.Error(), Err: err}
+	default:
+		return &BindError{Kind: BindErrorUnknown, Message: err.Error(), Err: err}
+	}
+}
+
+// fieldPathByTags converts the struct namespace from validator.FieldError, such as "User.Emails[0]", to the field path using bindFieldTags,
+// such as "emails[0]". Note that the embedded struct without tag will be omitted from the path, just like encoding/json.
+func fieldPathByTags(typ reflect.Type, namespace string) string {
+	segments := strings.Split(namespace, ".")
+	if len(segments) > 1 {
+		segments = segments[1:] // skip the root struct name
+	}
+
+	paths := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		name, index := segment, ""
+		if idx := strings.Index(segment, "["); idx != -1 {
+			name, index = segment[:idx], segment[idx:]
+		}
+		for typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+
+		path := name
+		var field reflect.StructField
+		ok := false
+		if typ != nil && typ.Kind() == reflect.Struct {
+			field, ok = typ.FieldByName(name)
+		}
+		if !ok {
+			typ = nil // unknown type, use the remaining namespace directly
+			paths = append(paths, segment)
+			continue
+		}
+
+		tagged := false
+		for _, tag := range bindFieldTags {
+			if t := strings.Split(field.Tag.Get(tag), ",")[0]; t != "" && t != "-" {
+				path, tagged = t, true
+				break
+			}
+		}
+		typ = field.Type
+		for i := strings.Count(index, "["); i > 0; i-- {
+			for typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			if k := typ.Kind(); k == reflect.Slice || k == reflect.Array || k == reflect.Map {
+				typ = typ.Elem()
+			}
+		}
+		if field.Anonymous && !tagged && index == "" {
+			continue // embedded struct
+		}
+		paths = append(paths, path+index)
+	}
+	return strings.Join(paths, ".")
+}
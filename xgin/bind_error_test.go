@@ -0,0 +1,112 @@
+package xgin
+
+import (
+	"errors"
+	"github.com/Aoi-hosizora/ahlib-more/xvalidator"
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBindError(t *testing.T) {
+	trans, err := GetValidatorTranslator(xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+
+	type Base struct {
+		ID uint64 `json:"id" binding:"required"`
+	}
+	type Address struct {
+		City string `json:"city" binding:"required"`
+	}
+	type testStruct struct {
+		Base
+		Name      string     `json:"name" form:"name" binding:"required"`
+		Age       int        `form:"age" binding:"gte=18"`
+		NoTag     string     `binding:"max=2"`
+		Emails    []string   `json:"emails" binding:"dive,email"`
+		Addresses []*Address `json:"addresses" binding:"dive"`
+		Address   *Address   `uri:"address"`
+		Skipped   string     `json:"-" form:"skipped" binding:"max=1"`
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	bind := func(body string) error {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest("POST", "/", strings.NewReader(body))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		return ctx.ShouldBindJSON(&testStruct{})
+	}
+
+	// validation
+	obj := &testStruct{}
+	err = bind(`{"Age": 1, "NoTag": "abc", "emails": ["a@b.c", "x"], "addresses": [{"city": "c"}, {}], "Address": {}, "Skipped": "xx"}`) // Skipped is ignored
+	be := ParseBindError(err, obj, trans)
+	xtesting.Equal(t, be.Kind, BindErrorValidation)
+	xtesting.Equal(t, be.Err, err)
+	xtesting.Equal(t, be.Fields, []*FieldError{
+		{Field: "id", Tag: "required", Param: "", Message: "ID is a required field"},
+		{Field: "name", Tag: "required", Param: "", Message: "Name is a required field"},
+		{Field: "age", Tag: "gte", Param: "18", Message: "Age must be 18 or greater"},
+		{Field: "NoTag", Tag: "max", Param: "2", Message: "NoTag must be a maximum of 2 characters in length"},
+		{Field: "emails[1]", Tag: "email", Param: "", Message: "Emails[1] must be a valid email address"},
+		{Field: "addresses[1].city", Tag: "required", Param: "", Message: "City is a required field"},
+		{Field: "address.city", Tag: "required", Param: "", Message: "City is a required field"},
+	})
+	xtesting.True(t, strings.HasPrefix(be.Error(), "ID is a required field; Name is a required field; "))
+	xtesting.Equal(t, errors.Unwrap(be), err)
+	be = ParseBindError(err, obj, nil)
+	xtesting.Equal(t, be.Fields[0].Message, "Key: 'testStruct.Base.ID' Error:Field validation for 'ID' failed on the 'required' tag")
+
+	// syntax and type mismatch
+	for _, tc := range []struct {
+		giveErr   error
+		wantKind  BindErrorKind
+		wantField string
+	}{
+		{nil, "", ""},
+		{bind(`{"name": "a",}`), BindErrorSyntax, ""},
+		{bind(``), BindErrorSyntax, ""},
+		{bind(`{"name": `), BindErrorSyntax, ""},
+		{bind(`{"name": 1}`), BindErrorTypeMismatch, "name"},
+		{bind(`[1]`), BindErrorTypeMismatch, ""},
+		{errors.New("test"), BindErrorUnknown, ""},
+	} {
+		be := ParseBindError(tc.giveErr, obj, trans)
+		if tc.giveErr == nil {
+			xtesting.Nil(t, be)
+			continue
+		}
+		xtesting.Equal(t, be.Kind, tc.wantKind)
+		xtesting.Equal(t, be.Message, tc.giveErr.Error())
+		if tc.wantField == "" {
+			xtesting.Equal(t, len(be.Fields), 0)
+		} else {
+			xtesting.Equal(t, be.Fields, []*FieldError{{Field: tc.wantField, Message: tc.giveErr.Error()}})
+		}
+	}
+
+	// type mismatch in query
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("GET", "/?age=abc", nil)
+	be = ParseBindError(ctx.ShouldBindQuery(obj), obj, trans)
+	xtesting.Equal(t, be.Kind, BindErrorTypeMismatch)
+
+	// field path
+	typ := reflect.TypeOf(obj)
+	for _, tc := range []struct {
+		give string
+		want string
+	}{
+		{"testStruct", "testStruct"},
+		{"testStruct.Name", "name"},
+		{"testStruct.Skipped", "skipped"},
+		{"testStruct.Unknown.Field", "Unknown.Field"},
+		{"testStruct.Addresses[0].City", "addresses[0].city"},
+		{"testStruct.Addresses[0].Unknown", "addresses[0].Unknown"},
+	} {
+		xtesting.Equal(t, fieldPathByTags(typ, tc.give), tc.want)
+	}
+}
@@ -0,0 +1,584 @@
+package xgin
+
+import (
+	"github.com/Aoi-hosizora/ahlib-more/xvalidator"
+	"github.com/Aoi-hosizora/ahlib/xtime"
+	"github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"net"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===================
+// binding translation
+// ===================
+
+// bindingTranslations stores the translations of custom bindings for each locale supported by xvalidator, the key of inner map is the locale
+// name returned by ut.Translator's Locale method, and "en" will be used if the locale is not found.
+var bindingTranslations = map[string]map[string]string{
+	"regexp": {
+		"en":      "{0} must matches regexp /{1}/",
+		"fr":      "{0} doit correspondre à l'expression régulière /{1}/",
+		"id":      "{0} harus cocok dengan regexp /{1}/",
+		"ja":      "{0}は正規表現/{1}/に一致する必要があります",
+		"nl":      "{0} moet overeenkomen met reguliere expressie /{1}/",
+		"pt_BR":   "{0} deve corresponder à expressão regular /{1}/",
+		"ru":      "{0} должен соответствовать регулярному выражению /{1}/",
+		"tr":      "{0}, /{1}/ düzenli ifadesiyle eşleşmelidir",
+		"zh":      "{0}必须匹配正则表达式/{1}/",
+		"zh_Hant": "{0}必須匹配正規表示式/{1}/",
+	},
+	"date": {
+		"en":      "{0} must be an RFC3339 date",
+		"fr":      "{0} doit être une date RFC3339",
+		"id":      "{0} harus berupa tanggal RFC3339",
+		"ja":      "{0}はRFC3339形式の日付でなければなりません",
+		"nl":      "{0} moet een RFC3339-datum zijn",
+		"pt_BR":   "{0} deve ser uma data RFC3339",
+		"ru":      "{0} должен быть датой в формате RFC3339",
+		"tr":      "{0} bir RFC3339 tarihi olmalıdır",
+		"zh":      "{0}必须是RFC3339格式的日期",
+		"zh_Hant": "{0}必須是RFC3339格式的日期",
+	},
+	"datetime": {
+		"en":      "{0} must be an RFC3339 datetime",
+		"fr":      "{0} doit être une date et heure RFC3339",
+		"id":      "{0} harus berupa tanggal dan waktu RFC3339",
+		"ja":      "{0}はRFC3339形式の日時でなければなりません",
+		"nl":      "{0} moet een RFC3339-datum en -tijd zijn",
+		"pt_BR":   "{0} deve ser uma data e hora RFC3339",
+		"ru":      "{0} должен быть датой и временем в формате RFC3339",
+		"tr":      "{0} bir RFC3339 tarih ve saati olmalıdır",
+		"zh":      "{0}必须是RFC3339格式的日期时间",
+		"zh_Hant": "{0}必須是RFC3339格式的日期時間",
+	},
+	"duration": {
+		"en":      "{0} must be a valid duration, such as 1h30m",
+		"fr":      "{0} doit être une durée valide, comme 1h30m",
+		"id":      "{0} harus berupa durasi yang valid, seperti 1h30m",
+		"ja":      "{0}は1h30mのような有効な期間でなければなりません",
+		"nl":      "{0} moet een geldige duur zijn, zoals 1h30m",
+		"pt_BR":   "{0} deve ser uma duração válida, como 1h30m",
+		"ru":      "{0} должен быть допустимой длительностью, например 1h30m",
+		"tr":      "{0} 1h30m gibi geçerli bir süre olmalıdır",
+		"zh":      "{0}必须是有效的时长，如1h30m",
+		"zh_Hant": "{0}必須是有效的時長，如1h30m",
+	},
+	"timezone": {
+		"en":      "{0} must be a valid IANA timezone name",
+		"fr":      "{0} doit être un nom de fuseau horaire IANA valide",
+		"id":      "{0} harus berupa nama zona waktu IANA yang valid",
+		"ja":      "{0}は有効なIANAタイムゾーン名でなければなりません",
+		"nl":      "{0} moet een geldige IANA-tijdzonenaam zijn",
+		"pt_BR":   "{0} deve ser um nome de fuso horário IANA válido",
+		"ru":      "{0} должен быть допустимым названием часового пояса IANA",
+		"tr":      "{0} geçerli bir IANA saat dilimi adı olmalıdır",
+		"zh":      "{0}必须是有效的IANA时区名称",
+		"zh_Hant": "{0}必須是有效的IANA時區名稱",
+	},
+	"cron": {
+		"en":      "{0} must be a valid cron expression",
+		"fr":      "{0} doit être une expression cron valide",
+		"id":      "{0} harus berupa ekspresi cron yang valid",
+		"ja":      "{0}は有効なcron式でなければなりません",
+		"nl":      "{0} moet een geldige cron-expressie zijn",
+		"pt_BR":   "{0} deve ser uma expressão cron válida",
+		"ru":      "{0} должен быть допустимым cron-выражением",
+		"tr":      "{0} geçerli bir cron ifadesi olmalıdır",
+		"zh":      "{0}必须是有效的cron表达式",
+		"zh_Hant": "{0}必須是有效的cron表達式",
+	},
+	"semver": {
+		"en":      "{0} must be a valid semantic version",
+		"fr":      "{0} doit être une version sémantique valide",
+		"id":      "{0} harus berupa versi semantik yang valid",
+		"ja":      "{0}は有効なセマンティックバージョンでなければなりません",
+		"nl":      "{0} moet een geldige semantische versie zijn",
+		"pt_BR":   "{0} deve ser uma versão semântica válida",
+		"ru":      "{0} должен быть допустимой семантической версией",
+		"tr":      "{0} geçerli bir anlamsal sürüm olmalıdır",
+		"zh":      "{0}必须是有效的语义化版本",
+		"zh_Hant": "{0}必須是有效的語意化版本",
+	},
+	"hexcolor_ext": {
+		"en":      "{0} must be a valid hex color",
+		"fr":      "{0} doit être une couleur hexadécimale valide",
+		"id":      "{0} harus berupa warna heksadesimal yang valid",
+		"ja":      "{0}は有効な16進数カラーでなければなりません",
+		"nl":      "{0} moet een geldige hexadecimale kleur zijn",
+		"pt_BR":   "{0} deve ser uma cor hexadecimal válida",
+		"ru":      "{0} должен быть допустимым шестнадцатеричным цветом",
+		"tr":      "{0} geçerli bir onaltılık renk olmalıdır",
+		"zh":      "{0}必须是有效的十六进制颜色",
+		"zh_Hant": "{0}必須是有效的十六進位顏色",
+	},
+	"cidrs": {
+		"en":      "{0} must be a comma-separated list of valid CIDR notations",
+		"fr":      "{0} doit être une liste de notations CIDR valides séparées par des virgules",
+		"id":      "{0} harus berupa daftar notasi CIDR valid yang dipisahkan koma",
+		"ja":      "{0}はカンマ区切りの有効なCIDR表記のリストでなければなりません",
+		"nl":      "{0} moet een door komma's gescheiden lijst van geldige CIDR-notaties zijn",
+		"pt_BR":   "{0} deve ser uma lista de notações CIDR válidas separadas por vírgulas",
+		"ru":      "{0} должен быть списком допустимых CIDR-нотаций через запятую",
+		"tr":      "{0} virgülle ayrılmış geçerli CIDR gösterimlerinin bir listesi olmalıdır",
+		"zh":      "{0}必须是以逗号分隔的有效CIDR列表",
+		"zh_Hant": "{0}必須是以逗號分隔的有效CIDR列表",
+	},
+	"csv_oneof": {
+		"en":      "{0} must be comma-separated values of [{1}]",
+		"fr":      "{0} doit être des valeurs séparées par des virgules parmi [{1}]",
+		"id":      "{0} harus berupa nilai yang dipisahkan koma dari [{1}]",
+		"ja":      "{0}は[{1}]のカンマ区切りの値でなければなりません",
+		"nl":      "{0} moet door komma's gescheiden waarden uit [{1}] zijn",
+		"pt_BR":   "{0} deve ser valores separados por vírgulas de [{1}]",
+		"ru":      "{0} должен быть списком значений из [{1}] через запятую",
+		"tr":      "{0} [{1}] değerlerinden virgülle ayrılmış değerler olmalıdır",
+		"zh":      "{0}必须是以逗号分隔的[{1}]中的值",
+		"zh_Hant": "{0}必須是以逗號分隔的[{1}]中的值",
+	},
+	"date_gtfield": {
+		"en":      "{0} must be after {1}",
+		"fr":      "{0} doit être postérieur à {1}",
+		"id":      "{0} harus setelah {1}",
+		"ja":      "{0}は{1}より後でなければなりません",
+		"nl":      "{0} moet na {1} liggen",
+		"pt_BR":   "{0} deve ser posterior a {1}",
+		"ru":      "{0} должен быть позже {1}",
+		"tr":      "{0}, {1} alanından sonra olmalıdır",
+		"zh":      "{0}必须晚于{1}",
+		"zh_Hant": "{0}必須晚於{1}",
+	},
+	"date_gtefield": {
+		"en":      "{0} must be after or equal to {1}",
+		"fr":      "{0} doit être postérieur ou égal à {1}",
+		"id":      "{0} harus setelah atau sama dengan {1}",
+		"ja":      "{0}は{1}以降でなければなりません",
+		"nl":      "{0} moet na of gelijk aan {1} liggen",
+		"pt_BR":   "{0} deve ser posterior ou igual a {1}",
+		"ru":      "{0} должен быть не раньше {1}",
+		"tr":      "{0}, {1} alanından sonra veya ona eşit olmalıdır",
+		"zh":      "{0}必须晚于或等于{1}",
+		"zh_Hant": "{0}必須晚於或等於{1}",
+	},
+	"exactly_one_of": {
+		"en":      "{0}: exactly one of [{1}] must be provided",
+		"fr":      "{0} : exactement un de [{1}] doit être fourni",
+		"id":      "{0}: tepat satu dari [{1}] harus diisi",
+		"ja":      "{0}: [{1}]のうち1つだけを指定する必要があります",
+		"nl":      "{0}: precies één van [{1}] moet worden opgegeven",
+		"pt_BR":   "{0}: exatamente um de [{1}] deve ser fornecido",
+		"ru":      "{0}: должно быть указано ровно одно из [{1}]",
+		"tr":      "{0}: [{1}] alanlarından tam olarak biri sağlanmalıdır",
+		"zh":      "{0}：[{1}]中必须有且仅有一个被提供",
+		"zh_Hant": "{0}：[{1}]中必須有且僅有一個被提供",
+	},
+}
+
+// addBindingTranslator adds the translation of given custom binding tag to given ut.Translator, using the locale of translator.
+func addBindingTranslator(translator ut.Translator, tag string) error {
+	return AddTranslator(translator, tag, bindingTranslation(tag, translator.Locale()), true)
+}
+
+// bindingTranslation returns the translation message of given custom binding tag for given locale, defaults to the "en" message.
+func bindingTranslation(tag, locale string) string {
+	messages := bindingTranslations[tag]
+	message, ok := messages[locale]
+	if !ok {
+		message = messages["en"]
+	}
+	return message
+}
+
+// addAllBindingTranslators adds the translations of all the custom bindings in this package to given ut.Translator, using given addFn,
+// such as AddTranslator and Validator.AddTranslator.
+func addAllBindingTranslators(translator ut.Translator, addFn func(ut.Translator, string, string, bool) error) error {
+	tags := make([]string, 0, len(bindingTranslations))
+	for tag := range bindingTranslations {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		if err := addFn(translator, tag, bindingTranslation(tag, translator.Locale()), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// =====================
+// extended binding pack
+// =====================
+
+// DurationValidator represents a validator for Go duration strings, such as "300ms", "1h30m" and "-1.5h", see time.ParseDuration.
+func DurationValidator() validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		text, ok := fl.Field().Interface().(string)
+		if !ok {
+			return false
+		}
+		_, err := time.ParseDuration(text)
+		return err == nil
+	}
+}
+
+// TimezoneValidator represents a validator for IANA timezone names, such as "UTC" and "Asia/Tokyo", note that "Local" is not allowed.
+func TimezoneValidator() validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		text, ok := fl.Field().Interface().(string)
+		if !ok || text == "" || text == "Local" {
+			return false
+		}
+		_, err := time.LoadLocation(text)
+		return err == nil
+	}
+}
+
+// cronFieldRange represents the value range and names of a cron field.
+type cronFieldRange struct {
+	min, max int
+	names    []string // names[i] means min+i
+	question bool     // allow "?"
+}
+
+// cronFieldRanges represents the five fields of a standard cron expression: minute, hour, day of month, month and day of week.
+var cronFieldRanges = []*cronFieldRange{
+	{min: 0, max: 59},
+	{min: 0, max: 23},
+	{min: 1, max: 31, question: true},
+	{min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}, question: true}, // 0 and 7 are both sunday
+}
+
+// parse parses a single value of the cron field, which can be a number or a name.
+func (c *cronFieldRange) parse(s string) (int, bool) {
+	for idx, name := range c.names {
+		if strings.EqualFold(s, name) {
+			return c.min + idx, true
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < c.min || v > c.max {
+		return 0, false
+	}
+	return v, true
+}
+
+// validateCronField checks given cron field, which is a comma-separated list of "*", "?", "a", "a-b", "*/n" or "a-b/n".
+func validateCronField(field string, r *cronFieldRange) bool {
+	for _, part := range strings.Split(field, ",") {
+		if idx := strings.Index(part, "/"); idx != -1 {
+			step, err := strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return false
+			}
+			part = part[:idx]
+		}
+		if part == "*" || (part == "?" && r.question) {
+			continue
+		}
+		lo, hi := part, part
+		if idx := strings.Index(part, "-"); idx != -1 {
+			lo, hi = part[:idx], part[idx+1:]
+		}
+		l, ok1 := r.parse(lo)
+		h, ok2 := r.parse(hi)
+		if !ok1 || !ok2 || l > h {
+			return false
+		}
+	}
+	return true
+}
+
+// CronValidator represents a validator for standard cron expressions with five fields, such as "*/5 * * * *" and "0 9 * * MON-FRI", the
+// descriptors such as "@daily", "@hourly" and "@every 1h30m" are also allowed.
+func CronValidator() validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		text, ok := fl.Field().Interface().(string)
+		if !ok {
+			return false
+		}
+		text = strings.TrimSpace(text)
+		if strings.HasPrefix(text, "@") {
+			switch text {
+			case "@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly":
+				return true
+			}
+			if strings.HasPrefix(text, "@every ") {
+				d, err := time.ParseDuration(strings.TrimSpace(text[len("@every "):]))
+				return err == nil && d > 0
+			}
+			return false
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != len(cronFieldRanges) {
+			return false
+		}
+		for idx, field := range fields {
+			if !validateCronField(field, cronFieldRanges[idx]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+var (
+	// semverRegexp is the semantic versioning regexp from https://semver.org, without "v" prefix.
+	semverRegexp = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+	// hexColorRegexp is the hex color regexp, which allows "#rgb", "#rgba", "#rrggbb" and "#rrggbbaa".
+	hexColorRegexp = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+)
+
+// CIDRListValidator represents a validator for comma-separated CIDR notations, such as "10.0.0.0/8, 192.168.0.0/16, ::1/128".
+func CIDRListValidator() validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		text, ok := fl.Field().Interface().(string)
+		if !ok {
+			return false
+		}
+		for _, cidr := range strings.Split(text, ",") {
+			if _, _, err := net.ParseCIDR(strings.TrimSpace(cidr)); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// CSVOneofValidator represents a validator for comma-separated enum values with param, just like `csv_oneof=a b c`, which means each of the
+// values, such as "a,c", must be one of the space-separated values in param.
+func CSVOneofValidator() validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		text, ok := fl.Field().Interface().(string)
+		if !ok {
+			return false
+		}
+		allowed := strings.Fields(fl.Param())
+		for _, value := range strings.Split(text, ",") {
+			value = strings.TrimSpace(value)
+			found := false
+			for _, a := range allowed {
+				if value == a {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// parseDateValue parses given value to time.Time, the value can be a time.Time or a string in RFC3339 date or datetime format.
+func parseDateValue(v reflect.Value) (time.Time, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return time.Time{}, false
+	}
+	switch i := v.Interface().(type) {
+	case time.Time:
+		return i, !i.IsZero()
+	case string:
+		for _, layout := range []string{xtime.RFC3339Date, xtime.RFC3339DateTime} {
+			if t, err := time.Parse(layout, i); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// DateFieldValidator represents a validator comparing date with another field with param, just like `date_gtfield=StartDate`, which means
+// the date must be after (or equal to if orEqual is true) the date of given field. The dates can be time.Time or strings in RFC3339 date or
+// datetime format, and note that the validation will pass if the other field is empty or invalid, which should be checked by its own binding.
+func DateFieldValidator(orEqual bool) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		current, ok := parseDateValue(fl.Field())
+		if !ok {
+			return false
+		}
+		field, _, found := fl.GetStructFieldOK()
+		if !found {
+			return false
+		}
+		other, ok := parseDateValue(field)
+		if !ok {
+			return true
+		}
+		return current.After(other) || (orEqual && current.Equal(other))
+	}
+}
+
+// EnableDurationBinding enables duration validator to `duration`, see DurationValidator.
+func EnableDurationBinding() error {
+	return AddBinding("duration", DurationValidator())
+}
+
+// EnableDurationBindingTranslator enables duration validator (`duration`)'s translator to given ut.Translator, the message is chosen by
+// translator's locale.
+func EnableDurationBindingTranslator(translator ut.Translator) error {
+	return addBindingTranslator(translator, "duration")
+}
+
+// EnableTimezoneBinding enables timezone validator to `timezone`, see TimezoneValidator.
+func EnableTimezoneBinding() error {
+	return AddBinding("timezone", TimezoneValidator())
+}
+
+// EnableTimezoneBindingTranslator enables timezone validator (`timezone`)'s translator to given ut.Translator, the message is chosen by
+// translator's locale.
+func EnableTimezoneBindingTranslator(translator ut.Translator) error {
+	return addBindingTranslator(translator, "timezone")
+}
+
+// EnableCronBinding enables cron expression validator to `cron`, see CronValidator.
+func EnableCronBinding() error {
+	return AddBinding("cron", CronValidator())
+}
+
+// EnableCronBindingTranslator enables cron expression validator (`cron`)'s translator to given ut.Translator, the message is chosen by
+// translator's locale.
+func EnableCronBindingTranslator(translator ut.Translator) error {
+	return addBindingTranslator(translator, "cron")
+}
+
+// EnableSemverBinding enables semantic version validator to `semver`, such as "1.0.0-beta.1+build.2".
+func EnableSemverBinding() error {
+	return AddBinding("semver", xvalidator.RegexpValidator(semverRegexp))
+}
+
+// EnableSemverBindingTranslator enables semantic version validator (`semver`)'s translator to given ut.Translator, the message is chosen
+// by translator's locale.
+func EnableSemverBindingTranslator(translator ut.Translator) error {
+	return addBindingTranslator(translator, "semver")
+}
+
+// EnableHexColorBinding enables hex color validator to `hexcolor_ext`, which also allows "#rgba" and "#rrggbbaa" comparing with validator's
+// builtin `hexcolor`, note that the builtin one is kept unchanged.
+func EnableHexColorBinding() error {
+	return AddBinding("hexcolor_ext", xvalidator.RegexpValidator(hexColorRegexp))
+}
+
+// EnableHexColorBindingTranslator enables hex color validator (`hexcolor_ext`)'s translator to given ut.Translator, the message is chosen by
+// translator's locale.
+func EnableHexColorBindingTranslator(translator ut.Translator) error {
+	return addBindingTranslator(translator, "hexcolor_ext")
+}
+
+// EnableCIDRListBinding enables comma-separated cidr list validator to `cidrs`, see CIDRListValidator.
+func EnableCIDRListBinding() error {
+	return AddBinding("cidrs", CIDRListValidator())
+}
+
+// EnableCIDRListBindingTranslator enables comma-separated cidr list validator (`cidrs`)'s translator to given ut.Translator, the message
+// is chosen by translator's locale.
+func EnableCIDRListBindingTranslator(translator ut.Translator) error {
+	return addBindingTranslator(translator, "cidrs")
+}
+
+// EnableCSVOneofBinding enables comma-separated enum validator to `csv_oneof`, see CSVOneofValidator.
+func EnableCSVOneofBinding() error {
+	return AddBinding("csv_oneof", CSVOneofValidator())
+}
+
+// EnableCSVOneofBindingTranslator enables comma-separated enum validator (`csv_oneof`)'s translator to given ut.Translator, the message
+// is chosen by translator's locale.
+func EnableCSVOneofBindingTranslator(translator ut.Translator) error {
+	return addBindingTranslator(translator, "csv_oneof")
+}
+
+// EnableDateRangeBinding enables date comparing validators to `date_gtfield` and `date_gtefield`, see DateFieldValidator.
+//
+// Example:
+// 	type Range struct {
+// 		StartDate string `form:"start_date" binding:"required,date"`
+// 		EndDate   string `form:"end_date"   binding:"required,date,date_gtefield=StartDate"`
+// 	}
+func EnableDateRangeBinding() error {
+	if err := AddBinding("date_gtfield", DateFieldValidator(false)); err != nil {
+		return err
+	}
+	return AddBinding("date_gtefield", DateFieldValidator(true))
+}
+
+// EnableDateRangeBindingTranslator enables date comparing validators (`date_gtfield` and `date_gtefield`)'s translators to given
+// ut.Translator, the messages are chosen by translator's locale.
+func EnableDateRangeBindingTranslator(translator ut.Translator) error {
+	if err := addBindingTranslator(translator, "date_gtfield"); err != nil {
+		return err
+	}
+	return addBindingTranslator(translator, "date_gtefield")
+}
+
+// =========================
+// struct level binding pack
+// =========================
+
+// ExactlyOneOfStructValidator represents a struct level validator which checks that exactly one of given fields is set (non-zero), such as
+// "exactly one of email and phone". The `exactly_one_of` error will be reported on the first field, with the space-separated field names as
+// param, and the missing fields will be treated as unset. Also see AddStructBinding and EnableExactlyOneOfBindingTranslator.
+//
+// Example:
+// 	_ = xgin.AddStructBinding(xgin.ExactlyOneOfStructValidator("Email", "Phone"), RegisterRequest{})
+func ExactlyOneOfStructValidator(fields ...string) validator.StructLevelFunc {
+	param := strings.Join(fields, " ")
+	return func(sl validator.StructLevel) {
+		if len(fields) == 0 {
+			return
+		}
+		current := sl.Current()
+		count := 0
+		for _, name := range fields {
+			if field := current.FieldByName(name); field.IsValid() && !field.IsZero() {
+				count++
+			}
+		}
+		if count != 1 {
+			var value interface{}
+			if field := current.FieldByName(fields[0]); field.IsValid() && field.CanInterface() {
+				value = field.Interface()
+			}
+			sl.ReportError(value, fields[0], fields[0], "exactly_one_of", param)
+		}
+	}
+}
+
+// DateAfterStructValidator represents a struct level validator which checks that the date of given field is after (or equal to if orEqual
+// is true) the date of other field, such as "end_time after start_time". The dates can be time.Time or strings in RFC3339 date or datetime
+// format, and the validation will be skipped if any of the dates is empty or invalid. The `date_gtfield` (or `date_gtefield`) error will be
+// reported on given field with other field name as param, also see AddStructBinding and EnableDateRangeBindingTranslator.
+//
+// Example:
+// 	_ = xgin.AddStructBinding(xgin.DateAfterStructValidator("EndTime", "StartTime", false), CreateEventRequest{})
+func DateAfterStructValidator(field, otherField string, orEqual bool) validator.StructLevelFunc {
+	tag := "date_gtfield"
+	if orEqual {
+		tag = "date_gtefield"
+	}
+	return func(sl validator.StructLevel) {
+		current := sl.Current()
+		value := reflect.Indirect(current.FieldByName(field))
+		date, ok1 := parseDateValue(value)
+		other, ok2 := parseDateValue(reflect.Indirect(current.FieldByName(otherField)))
+		if !ok1 || !ok2 {
+			return
+		}
+		if !date.After(other) && !(orEqual && date.Equal(other)) {
+			sl.ReportError(value.Interface(), field, field, tag, otherField)
+		}
+	}
+}
+
+// EnableExactlyOneOfBindingTranslator enables exactly one of struct level validator (`exactly_one_of`)'s translator to given ut.Translator,
+// the message is chosen by translator's locale, see ExactlyOneOfStructValidator.
+func EnableExactlyOneOfBindingTranslator(translator ut.Translator) error {
+	return addBindingTranslator(translator, "exactly_one_of")
+}
@@ -0,0 +1,331 @@
+package xgin
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/Aoi-hosizora/ahlib-more/xvalidator"
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBindingTranslations(t *testing.T) {
+	locales := []string{"en", "fr", "id", "ja", "nl", "pt_BR", "ru", "tr", "zh", "zh_Hant"}
+	for tag, messages := range bindingTranslations {
+		xtesting.Equal(t, len(messages), len(locales))
+		for _, locale := range locales {
+			message, ok := messages[locale]
+			xtesting.True(t, ok)
+			for _, placeholder := range []string{"{0}", "{1}"} {
+				if strings.Contains(messages["en"], placeholder) {
+					xtesting.True(t, strings.Contains(message, placeholder), tag, locale)
+				}
+			}
+		}
+	}
+}
+
+func TestExtendedBindings(t *testing.T) {
+	v := validator.New()
+	xtesting.Nil(t, v.RegisterValidation("duration", DurationValidator()))
+	xtesting.Nil(t, v.RegisterValidation("timezone", TimezoneValidator()))
+	xtesting.Nil(t, v.RegisterValidation("cron", CronValidator()))
+	xtesting.Nil(t, v.RegisterValidation("semver", xvalidator.RegexpValidator(semverRegexp)))
+	xtesting.Nil(t, v.RegisterValidation("hexcolor_ext", xvalidator.RegexpValidator(hexColorRegexp)))
+	xtesting.Nil(t, v.RegisterValidation("cidrs", CIDRListValidator()))
+	xtesting.Nil(t, v.RegisterValidation("csv_oneof", CSVOneofValidator()))
+
+	for _, tc := range []struct {
+		giveTag   string
+		giveValue interface{}
+		wantOk    bool
+	}{
+		{"duration", "1h30m", true},
+		{"duration", "-1.5h", true},
+		{"duration", "300ms", true},
+		{"duration", "0", true},
+		{"duration", "1d", false},
+		{"duration", "", false},
+		{"duration", 1, false},
+
+		{"timezone", "UTC", true},
+		{"timezone", "Asia/Tokyo", true},
+		{"timezone", "America/New_York", true},
+		{"timezone", "Local", false},
+		{"timezone", "Mars/Olympus", false},
+		{"timezone", "", false},
+		{"timezone", 1, false},
+
+		{"cron", "* * * * *", true},
+		{"cron", "*/5 * * * *", true},
+		{"cron", "0 9 * * MON-FRI", true},
+		{"cron", "0 0 1,15 jan-jun/2 ?", true},
+		{"cron", "0-59/10 0-23 1-31 1-12 0-7", true},
+		{"cron", "@daily", true},
+		{"cron", "@every 1h30m", true},
+		{"cron", "@every 0s", false},
+		{"cron", "@every", false},
+		{"cron", "@weekday", false},
+		{"cron", "* * * *", false},
+		{"cron", "* * * * * *", false},
+		{"cron", "60 * * * *", false},
+		{"cron", "* 24 * * *", false},
+		{"cron", "* * 0 * *", false},
+		{"cron", "? * * * *", false},
+		{"cron", "*/0 * * * *", false},
+		{"cron", "5-1 * * * *", false},
+		{"cron", "* * * FOO *", false},
+		{"cron", 1, false},
+
+		{"semver", "1.0.0", true},
+		{"semver", "1.0.0-beta.1+build.2", true},
+		{"semver", "0.10.20-rc.1", true},
+		{"semver", "v1.0.0", false},
+		{"semver", "1.0", false},
+		{"semver", "01.0.0", false},
+		{"semver", 1, false},
+
+		{"hexcolor_ext", "#fff", true},
+		{"hexcolor_ext", "#FFFA", true},
+		{"hexcolor_ext", "#00ff00", true},
+		{"hexcolor_ext", "#00ff0080", true},
+		{"hexcolor_ext", "#ff", false},
+		{"hexcolor_ext", "#00ff0", false},
+		{"hexcolor_ext", "00ff00", false},
+		{"hexcolor_ext", "#gggggg", false},
+
+		{"cidrs", "10.0.0.0/8", true},
+		{"cidrs", "10.0.0.0/8, 192.168.0.0/16,::1/128", true},
+		{"cidrs", "10.0.0.0/8,", false},
+		{"cidrs", "10.0.0.1", false},
+		{"cidrs", "", false},
+		{"cidrs", 1, false},
+
+		{"csv_oneof=a b c", "a", true},
+		{"csv_oneof=a b c", "a,c", true},
+		{"csv_oneof=a b c", "b, c ,a", true},
+		{"csv_oneof=a b c", "a,d", false},
+		{"csv_oneof=a b c", "a,,b", false},
+		{"csv_oneof=a b c", "", false},
+		{"csv_oneof=a b c", 1, false},
+	} {
+		err := v.Var(tc.giveValue, tc.giveTag)
+		xtesting.Equal(t, err == nil, tc.wantOk, tc.giveTag, tc.giveValue)
+	}
+
+	// date range
+	xtesting.Nil(t, v.RegisterValidation("date_gtfield", DateFieldValidator(false)))
+	xtesting.Nil(t, v.RegisterValidation("date_gtefield", DateFieldValidator(true)))
+	type dateStruct struct {
+		Start   string     `validate:"omitempty"`
+		End     string     `validate:"date_gtfield=Start"`
+		EndE    string     `validate:"date_gtefield=Start"`
+		StartT  time.Time  `validate:"omitempty"`
+		EndT    *time.Time `validate:"omitempty,date_gtfield=StartT"`
+		Missing string     `validate:"omitempty,date_gtfield=Unknown"`
+	}
+	now := time.Now()
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+	for _, tc := range []struct {
+		give    *dateStruct
+		wantErr []string
+	}{
+		{&dateStruct{Start: "2021-01-01", End: "2021-01-02", EndE: "2021-01-01"}, nil},
+		{&dateStruct{Start: "2021-01-01T00:00:00Z", End: "2021-01-01T00:00:01Z", EndE: "2021-01-01T00:00:00Z"}, nil},
+		{&dateStruct{Start: "2021-01-01", End: "2021-01-01", EndE: "2020-12-31"}, []string{"End", "EndE"}},
+		{&dateStruct{Start: "", End: "2021-01-01", EndE: "2021-01-01"}, nil},
+		{&dateStruct{Start: "invalid", End: "2021-01-01", EndE: "2021-01-01"}, nil},
+		{&dateStruct{Start: "2021-01-01", End: "invalid", EndE: ""}, []string{"End", "EndE"}},
+		{&dateStruct{End: "2021-01-01", EndE: "2021-01-01", StartT: now, EndT: &later}, nil},
+		{&dateStruct{End: "2021-01-01", EndE: "2021-01-01", StartT: now, EndT: &earlier}, []string{"EndT"}},
+		{&dateStruct{End: "2021-01-01", EndE: "2021-01-01", Missing: "2021-01-01"}, []string{"Missing"}},
+	} {
+		err := v.Struct(tc.give)
+		fields := make([]string, 0)
+		if err != nil {
+			for _, fe := range err.(validator.ValidationErrors) {
+				fields = append(fields, fe.Field())
+			}
+		}
+		xtesting.Equal(t, fields, append(make([]string, 0), tc.wantErr...))
+	}
+}
+
+func TestEnableExtendedBindings(t *testing.T) {
+	registry := NewTranslatorRegistry()
+	en, err := registry.Register(xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+	zh, err := registry.Register(xvalidator.ZhLocaleTranslator(), xvalidator.ZhTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+	fr, err := GetValidatorTranslator(xvalidator.FrLocaleTranslator(), xvalidator.FrTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+
+	for _, fn := range []func() error{
+		EnableDurationBinding, EnableTimezoneBinding, EnableCronBinding, EnableSemverBinding,
+		EnableHexColorBinding, EnableCIDRListBinding, EnableCSVOneofBinding, EnableDateRangeBinding, EnableRFC3339DateBinding,
+	} {
+		xtesting.Nil(t, fn())
+	}
+	for _, fn := range []func(translator ut.Translator) error{
+		EnableDurationBindingTranslator, EnableTimezoneBindingTranslator, EnableCronBindingTranslator, EnableSemverBindingTranslator,
+		EnableHexColorBindingTranslator, EnableCIDRListBindingTranslator, EnableCSVOneofBindingTranslator, EnableDateRangeBindingTranslator,
+	} {
+		xtesting.Nil(t, fn(fr))
+	}
+
+	type testStruct struct {
+		Timeout  string `binding:"duration"`
+		Timezone string `binding:"timezone"`
+		Schedule string `binding:"cron"`
+		Version  string `binding:"semver"`
+		Color    string `binding:"hexcolor_ext"`
+		Networks string `binding:"cidrs"`
+		Fields   string `binding:"csv_oneof=id name"`
+		Start    string `binding:"date"`
+		End      string `binding:"date_gtfield=Start"`
+	}
+	val, err := GetValidatorEngine()
+	xtesting.Nil(t, err)
+	obj := &testStruct{Start: "2021-01-02", End: "2021-01-01"}
+	verr := val.Struct(obj)
+	xtesting.NotNil(t, verr)
+	xtesting.Nil(t, val.Var("#FFFA", "hexcolor_ext"))
+	xtesting.NotNil(t, val.Var("#FFFA", "hexcolor")) // builtin is not overridden
+
+	for _, tc := range []struct {
+		giveTranslator ut.Translator
+		wantMessages   []string
+	}{
+		{en, []string{
+			"Timeout must be a valid duration, such as 1h30m",
+			"Timezone must be a valid IANA timezone name",
+			"Schedule must be a valid cron expression",
+			"Version must be a valid semantic version",
+			"Color must be a valid hex color",
+			"Networks must be a comma-separated list of valid CIDR notations",
+			"Fields must be comma-separated values of [id name]",
+			"End must be after Start",
+		}},
+		{zh, []string{
+			"Timeout必须是有效的时长，如1h30m",
+			"Timezone必须是有效的IANA时区名称",
+			"Schedule必须是有效的cron表达式",
+			"Version必须是有效的语义化版本",
+			"Color必须是有效的十六进制颜色",
+			"Networks必须是以逗号分隔的有效CIDR列表",
+			"Fields必须是以逗号分隔的[id name]中的值",
+			"End必须晚于Start",
+		}},
+		{fr, []string{
+			"Timeout doit être une durée valide, comme 1h30m",
+			"Timezone doit être un nom de fuseau horaire IANA valide",
+			"Schedule doit être une expression cron valide",
+			"Version doit être une version sémantique valide",
+			"Color doit être une couleur hexadécimale valide",
+			"Networks doit être une liste de notations CIDR valides séparées par des virgules",
+			"Fields doit être des valeurs séparées par des virgules parmi [id name]",
+			"End doit être postérieur à Start",
+		}},
+	} {
+		be := ParseBindError(verr, obj, tc.giveTranslator)
+		messages := make([]string, 0, len(be.Fields))
+		for _, f := range be.Fields {
+			messages = append(messages, f.Message)
+		}
+		xtesting.Equal(t, messages, tc.wantMessages)
+	}
+}
+
+func TestStructBindings(t *testing.T) {
+	registry := NewTranslatorRegistry()
+	en, err := registry.Register(xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+	zh, err := registry.Register(xvalidator.ZhLocaleTranslator(), xvalidator.ZhTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+	xtesting.Nil(t, EnableExactlyOneOfBindingTranslator(en))
+
+	type contactRequest struct {
+		Email string  `json:"email"`
+		Phone *string `json:"phone"`
+	}
+	type eventRequest struct {
+		StartTime time.Time  `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+		StartDate string     `json:"start_date"`
+		EndDate   string     `json:"end_date"`
+	}
+	type nullRequest struct {
+		Name sql.NullString `json:"name" binding:"required"`
+	}
+	xtesting.Nil(t, AddStructBinding(ExactlyOneOfStructValidator("Email", "Phone"), contactRequest{}))
+	xtesting.Nil(t, AddStructBinding(func(sl validator.StructLevel) {
+		DateAfterStructValidator("EndTime", "StartTime", false)(sl)
+		DateAfterStructValidator("EndDate", "StartDate", true)(sl)
+	}, &eventRequest{}))
+	xtesting.Nil(t, AddCustomTypeFunc(func(field reflect.Value) interface{} {
+		if valuer, ok := field.Interface().(driver.Valuer); ok {
+			v, _ := valuer.Value()
+			return v
+		}
+		return nil
+	}, sql.NullString{}))
+
+	val, err := GetValidatorEngine()
+	xtesting.Nil(t, err)
+	phone := "123"
+	now := time.Now()
+	earlier := now.Add(-time.Second)
+	for _, tc := range []struct {
+		give      interface{}
+		wantField []string
+		wantEn    []string
+		wantZh    []string
+	}{
+		{&contactRequest{Email: "a@b.c"}, nil, nil, nil},
+		{&contactRequest{Phone: &phone}, nil, nil, nil},
+		{&contactRequest{}, []string{"email"}, []string{"Email: exactly one of [Email Phone] must be provided"}, []string{"Email：[Email Phone]中必须有且仅有一个被提供"}},
+		{&contactRequest{Email: "a@b.c", Phone: &phone}, []string{"email"}, []string{"Email: exactly one of [Email Phone] must be provided"}, nil},
+		{&eventRequest{}, nil, nil, nil},
+		{&eventRequest{StartTime: now, EndTime: &now, StartDate: "2021-01-01", EndDate: "2021-01-01"}, []string{"end_time"},
+			[]string{"EndTime must be after StartTime"}, []string{"EndTime必须晚于StartTime"}},
+		{&eventRequest{StartTime: now, EndTime: &earlier, StartDate: "2021-01-02", EndDate: "2021-01-01"}, []string{"end_time", "end_date"},
+			[]string{"EndTime must be after StartTime", "EndDate must be after or equal to StartDate"}, nil},
+		{&eventRequest{StartDate: "2021-01-02", EndDate: "invalid"}, nil, nil, nil},
+		{&nullRequest{Name: sql.NullString{String: "xxx", Valid: true}}, nil, nil, nil},
+		{&nullRequest{Name: sql.NullString{String: "xxx", Valid: false}}, []string{"name"}, []string{"Name is a required field"}, nil},
+	} {
+		err := val.Struct(tc.give)
+		if tc.wantField == nil {
+			xtesting.Nil(t, err)
+			continue
+		}
+		be := ParseBindError(err, tc.give, en)
+		fields, messages := make([]string, 0), make([]string, 0)
+		for _, f := range be.Fields {
+			fields = append(fields, f.Field)
+			messages = append(messages, f.Message)
+		}
+		xtesting.Equal(t, fields, tc.wantField)
+		xtesting.Equal(t, messages, tc.wantEn)
+		if tc.wantZh != nil {
+			messages = messages[:0]
+			for _, f := range ParseBindError(err, tc.give, zh).Fields {
+				messages = append(messages, f.Message)
+			}
+			xtesting.Equal(t, messages, tc.wantZh)
+		}
+	}
+
+	// error
+	motoVal := binding.Validator
+	binding.Validator = &mockValidator{}
+	defer func() { binding.Validator = motoVal }()
+	xtesting.NotNil(t, AddStructBinding(ExactlyOneOfStructValidator(), contactRequest{}))
+	xtesting.NotNil(t, AddCustomTypeFunc(func(reflect.Value) interface{} { return nil }, sql.NullInt64{}))
+}
@@ -0,0 +1,215 @@
+package xgin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Aoi-hosizora/ahlib/xtime"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errDefaultsNotStructPointer = errors.New("xgin: ApplyDefaults only supports non-nil struct pointer")
+)
+
+// ApplyDefaults sets the zero-valued fields of given struct pointer to the values in their `default` tags, nested structs, non-nil struct
+// pointers and struct slices are also applied. The nil pointer field with `default` tag will be allocated, so `binding:"required"` is
+// satisfied. Note that unlike gin's `form:"name,default=x"`, this function works for all the binding sources, such as json body and uri.
+// Supported field types and default values:
+// 	string, bool, int, uint, float // "abc", "true", "-1", "1", "1.5"
+// 	time.Duration                  // "1h30m", see time.ParseDuration
+// 	time.Time, xtime.JsonDateTime  // "2021-01-01T00:00:00Z" or "2021-01-01", see xtime.RFC3339DateTime and xtime.RFC3339Date
+// 	slice and array of above       // "a,b,c", use "0x2C" for comma in element
+//
+// Example:
+// 	type ListRequest struct {
+// 		Page    int32         `form:"page"    default:"1"`
+// 		Orders  []string      `form:"orders"  default:"id,name"`
+// 		Timeout time.Duration `json:"timeout" default:"30s"`
+// 	}
+func ApplyDefaults(obj interface{}) error {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return errDefaultsNotStructPointer
+	}
+	return applyStructDefaults(val.Elem())
+}
+
+// applyStructDefaults applies the `default` tags of given struct value's fields.
+func applyStructDefaults(val reflect.Value) error {
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field, fieldVal := typ.Field(i), val.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		if def, ok := field.Tag.Lookup("default"); ok && fieldVal.CanSet() && isZeroValue(fieldVal) {
+			if err := setDefaultValue(fieldVal, def); err != nil {
+				return fmt.Errorf("xgin: invalid default value '%s' for field %s.%s: %v", def, typ.Name(), field.Name, err)
+			}
+			continue
+		}
+		if err := applyNestedDefaults(fieldVal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyNestedDefaults applies the `default` tags of nested struct, struct pointer and struct slice.
+func applyNestedDefaults(val reflect.Value) error {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if !val.IsNil() {
+			return applyNestedDefaults(val.Elem())
+		}
+	case reflect.Struct:
+		if !isTimeType(val.Type()) {
+			return applyStructDefaults(val)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			if err := applyNestedDefaults(val.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isZeroValue checks whether given value is zero value, such as 0, "", nil and empty slice.
+func isZeroValue(val reflect.Value) bool {
+	if val.Kind() == reflect.Slice || val.Kind() == reflect.Map {
+		return val.Len() == 0
+	}
+	return val.IsZero()
+}
+
+// isTimeType checks whether given type is time.Time or the type converted from time.Time, such as xtime.JsonDate.
+func isTimeType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && timeType.ConvertibleTo(typ)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setDefaultValue parses given default value and sets it to val.
+func setDefaultValue(val reflect.Value, def string) error {
+	typ := val.Type()
+	switch {
+	case typ.Kind() == reflect.Ptr:
+		ptr := reflect.New(typ.Elem())
+		if err := setDefaultValue(ptr.Elem(), def); err != nil {
+			return err
+		}
+		val.Set(ptr)
+		return nil
+	case typ == durationType:
+		d, err := time.ParseDuration(def)
+		if err != nil {
+			return err
+		}
+		val.SetInt(int64(d))
+		return nil
+	case isTimeType(typ):
+		t, err := time.Parse(xtime.RFC3339DateTime, def)
+		if err != nil {
+			if t, err = time.Parse(xtime.RFC3339Date, def); err != nil {
+				return err
+			}
+		}
+		val.Set(reflect.ValueOf(t).Convert(typ))
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		val.SetString(def)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		val.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(def, 10, typ.Bits())
+		if err != nil {
+			return err
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(def, 10, typ.Bits())
+		if err != nil {
+			return err
+		}
+		val.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(def, typ.Bits())
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case reflect.Slice, reflect.Array:
+		elems := strings.Split(def, ",")
+		if typ.Kind() == reflect.Slice {
+			val.Set(reflect.MakeSlice(typ, len(elems), len(elems)))
+		} else if len(elems) > val.Len() {
+			return fmt.Errorf("too many elements for %s", typ.String())
+		}
+		for i, elem := range elems {
+			if err := setDefaultValue(val.Index(i), strings.ReplaceAll(elem, "0x2C", ",")); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", typ.String())
+	}
+	return nil
+}
+
+// ShouldBindWithDefaults binds the request to obj using given binding.Binding, applies the `default` tags to zero-valued fields by
+// ApplyDefaults, and then validates obj, also see ShouldBindWith. Use UriBinding to bind route parameters.
+//
+// Example:
+// 	req := &ListRequest{}
+// 	if err := xgin.ShouldBindWithDefaults(c, req, binding.Query); err != nil {
+// 		...
+// 	}
+func ShouldBindWithDefaults(c *gin.Context, obj interface{}, b binding.Binding) error {
+	if err := bindWithoutValidation(c.Request, obj, b); err != nil {
+		return err
+	}
+	if err := ApplyDefaults(obj); err != nil {
+		return err
+	}
+	return validateWith(c, obj)
+}
+
+// UriBinding returns a binding.Binding for the route parameters of given gin.Context, which can be used in ShouldBindWith and
+// ShouldBindWithDefaults. Note that the parameters rewritten by AppRouter are also supported.
+func UriBinding(c *gin.Context) binding.Binding {
+	return &uriParamsBinding{params: c.Params}
+}
+
+// uriParamsBinding is a binding.Binding for route parameters, created by UriBinding.
+type uriParamsBinding struct {
+	params gin.Params
+}
+
+// Name implements binding.Binding.
+func (u *uriParamsBinding) Name() string {
+	return "uri"
+}
+
+// Bind implements binding.Binding, the request is not used.
+func (u *uriParamsBinding) Bind(_ *http.Request, obj interface{}) error {
+	m := make(map[string][]string)
+	for _, param := range u.params {
+		m[param.Key] = []string{param.Value}
+	}
+	return binding.Uri.BindUri(m, obj)
+}
@@ -0,0 +1,155 @@
+package xgin
+
+import (
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/Aoi-hosizora/ahlib/xtime"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type defaultsInner struct {
+	Level uint8 `json:"level" default:"3"`
+}
+
+type defaultsStruct struct {
+	defaultsInner
+	Name     string             `json:"name"     default:"anonymous"`
+	Enabled  bool               `json:"enabled"  default:"true"`
+	Count    int64              `json:"count"    default:"-1"`
+	Ratio    float32            `json:"ratio"    default:"0.5"`
+	Timeout  time.Duration      `json:"timeout"  default:"1m30s"`
+	Date     time.Time          `json:"date"     default:"2021-01-02"`
+	DateTime xtime.JsonDateTime `json:"datetime" default:"2021-01-02T03:04:05+08:00"`
+	Tags     []string           `json:"tags"     default:"a,b0x2Cc"`
+	Points   [3]int             `json:"points"   default:"1,2"`
+	Pointer  *int               `json:"pointer"  default:"5"`
+	Inner    *defaultsInner     `json:"inner"`
+	Inners   []defaultsInner    `json:"inners"`
+	NoTag    string             `json:"no_tag"`
+	private  string             `default:"x"`
+}
+
+func TestApplyDefaults(t *testing.T) {
+	obj := &defaultsStruct{Inner: &defaultsInner{}, Inners: []defaultsInner{{}, {Level: 1}}}
+	xtesting.Nil(t, ApplyDefaults(obj))
+	five := 5
+	loc := time.FixedZone("", 8*60*60)
+	xtesting.Equal(t, obj, &defaultsStruct{
+		defaultsInner: defaultsInner{Level: 3},
+		Name:          "anonymous",
+		Enabled:       true,
+		Count:         -1,
+		Ratio:         0.5,
+		Timeout:       90 * time.Second,
+		Date:          time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+		DateTime:      xtime.JsonDateTime(time.Date(2021, 1, 2, 3, 4, 5, 0, loc)),
+		Tags:          []string{"a", "b,c"},
+		Points:        [3]int{1, 2, 0},
+		Pointer:       &five,
+		Inner:         &defaultsInner{Level: 3},
+		Inners:        []defaultsInner{{Level: 3}, {Level: 1}},
+	})
+
+	// non-zero
+	obj = &defaultsStruct{Name: "x", Count: 2, Tags: []string{"c"}, Pointer: new(int)}
+	xtesting.Nil(t, ApplyDefaults(obj))
+	xtesting.Equal(t, obj.Name, "x")
+	xtesting.Equal(t, obj.Count, int64(2))
+	xtesting.Equal(t, obj.Tags, []string{"c"})
+	xtesting.Equal(t, *obj.Pointer, 0)
+	xtesting.Nil(t, obj.Inner)
+
+	// errors
+	for _, give := range []interface{}{nil, 0, defaultsStruct{}, (*defaultsStruct)(nil), &[]int{}} {
+		xtesting.Equal(t, ApplyDefaults(give), errDefaultsNotStructPointer)
+	}
+	for _, tc := range []struct {
+		give    interface{}
+		wantErr string
+	}{
+		{&struct{ A bool `default:"x"` }{}, "xgin: invalid default value 'x' for field .A: strconv.ParseBool: parsing \"x\": invalid syntax"},
+		{&struct{ A int8 `default:"128"` }{}, "xgin: invalid default value '128' for field .A: strconv.ParseInt: parsing \"128\": value out of range"},
+		{&struct{ A uint `default:"-1"` }{}, "xgin: invalid default value '-1' for field .A: strconv.ParseUint: parsing \"-1\": invalid syntax"},
+		{&struct{ A float64 `default:"x"` }{}, "xgin: invalid default value 'x' for field .A: strconv.ParseFloat: parsing \"x\": invalid syntax"},
+		{&struct{ A time.Duration `default:"1"` }{}, "xgin: invalid default value '1' for field .A: time: missing unit in duration \"1\""},
+		{&struct{ A time.Time `default:"2021"` }{}, "xgin: invalid default value '2021' for field .A: parsing time \"2021\" as \"2006-01-02\": cannot parse \"\" as \"-\""},
+		{&struct{ A [1]int `default:"1,2"` }{}, "xgin: invalid default value '1,2' for field .A: too many elements for [1]int"},
+		{&struct{ A []int `default:"1,x"` }{}, "xgin: invalid default value '1,x' for field .A: strconv.ParseInt: parsing \"x\": invalid syntax"},
+		{&struct{ A map[string]int `default:"x"` }{}, "xgin: invalid default value 'x' for field .A: unsupported type map[string]int"},
+		{&struct{ A []struct{ B int `default:"x"` } }{A: make([]struct{ B int `default:"x"` }, 1)}, "xgin: invalid default value 'x' for field .B: strconv.ParseInt: parsing \"x\": invalid syntax"},
+	} {
+		err := ApplyDefaults(tc.give)
+		xtesting.NotNil(t, err)
+		if err != nil {
+			xtesting.Equal(t, err.Error(), tc.wantErr)
+		}
+	}
+
+	// schema
+	schema := GenerateJSONSchema(&defaultsStruct{})
+	xtesting.Equal(t, schema.Properties["name"].Default, "anonymous")
+	xtesting.Equal(t, schema.Properties["count"].Default, int64(-1))
+	xtesting.Equal(t, schema.Properties["tags"].Default, []interface{}{"a", "b,c"})
+	xtesting.Equal(t, schema.Properties["level"].Default, int64(3))
+	xtesting.Nil(t, schema.Properties["timeout"].Default)
+}
+
+func TestShouldBindWithDefaults(t *testing.T) {
+	type testStruct struct {
+		ID    uint64 `uri:"id"    binding:"required" default:"1"`
+		Name  string `json:"name" binding:"required" default:"anonymous"`
+		Page  int32  `form:"page" binding:"min=1"    default:"1"`
+		Limit int32  `form:"limit" default:"x"`
+	}
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	ar := NewAppRouter(app, app)
+	handler := func(giveBinding func(*gin.Context) binding.Binding) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			obj := &testStruct{Limit: 10}
+			if err := ShouldBindWithDefaults(c, obj, giveBinding(c)); err != nil {
+				c.String(400, err.Error())
+				return
+			}
+			c.JSON(200, obj)
+		}
+	}
+	ar.POST("json", handler(func(*gin.Context) binding.Binding { return binding.JSON }))
+	ar.GET("query", handler(func(*gin.Context) binding.Binding { return binding.Query }))
+	ar.GET(":id", handler(UriBinding))
+	ar.Register()
+
+	for _, tc := range []struct {
+		giveMethod string
+		givePath   string
+		giveBody   string
+		wantCode   int
+		wantBody   string
+	}{
+		{"POST", "/json", `{}`, 200, `{"ID":1,"name":"anonymous","Page":1,"Limit":10}`},
+		{"POST", "/json", `{"name": "x"}`, 200, `{"ID":1,"name":"x","Page":1,"Limit":10}`},
+		{"POST", "/json", `{`, 400, "unexpected EOF"},
+		{"GET", "/query?page=3", ``, 200, `{"ID":1,"name":"anonymous","Page":3,"Limit":10}`},
+		{"GET", "/query?page=0", ``, 200, `{"ID":1,"name":"anonymous","Page":1,"Limit":10}`},
+		{"GET", "/query?page=x", ``, 400, `strconv.ParseInt: parsing "x": invalid syntax`},
+		{"GET", "/5", ``, 200, `{"ID":5,"name":"anonymous","Page":1,"Limit":10}`},
+		{"GET", "/0", ``, 200, `{"ID":1,"name":"anonymous","Page":1,"Limit":10}`},
+	} {
+		req := httptest.NewRequest(tc.giveMethod, tc.givePath, strings.NewReader(tc.giveBody))
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		xtesting.Equal(t, w.Code, tc.wantCode)
+		xtesting.Equal(t, w.Body.String(), tc.wantBody)
+	}
+
+	// invalid default
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest("GET", "/", nil)
+	err := ShouldBindWithDefaults(ctx, &testStruct{}, binding.Query)
+	xtesting.Equal(t, err.Error(), "xgin: invalid default value 'x' for field testStruct.Limit: strconv.ParseInt: parsing \"x\": invalid syntax")
+	xtesting.Equal(t, UriBinding(ctx).Name(), "uri")
+}
@@ -0,0 +1,344 @@
+package xgin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HarRecorder represents a recorder which collects request and response pairs to a ring buffer, and exports them in HTTP Archive (HAR 1.2)
+// format, which can be imported to browser's devtools directly. See http://www.softwareishard.com/blog/har-12-spec/.
+type HarRecorder struct {
+	mu      sync.Mutex
+	entries []*harEntry // ring buffer
+	next    int         // next index to write
+	full    bool        // buffer is full
+	opt     *dumpRequestOptions
+	dumper  gin.HandlerFunc
+}
+
+const (
+	panicNonPositiveCapacity = "xgin: non-positive capacity for HarRecorder"
+)
+
+// NewHarRecorder creates a HarRecorder with given capacity and DumpRequestOption-s, panics when capacity is not positive. The oldest entry will be
+// dropped when the recorder is full. Note that header options and WithSecretFields will be applied to both request and response, and request and
+// response body will be recorded only when WithBodyLimit is used.
+//
+// Example:
+// 	har := xgin.NewHarRecorder(100, xgin.WithSecretHeaders("Authorization"), xgin.WithBodyLimit(4096))
+// 	app.Use(func(c *gin.Context) {
+// 		start := time.Now()
+// 		c.Next()
+// 		end := time.Now()
+// 		xgin.LogToLogrus(logger, c, start, end)
+// 		har.Record(c, start, end) // use the same times
+// 	})
+// 	app.Use(har.Capture())
+// 	app.GET("/debug/har", har.Handler())
+func NewHarRecorder(capacity int, options ...DumpRequestOption) *HarRecorder {
+	if capacity <= 0 {
+		panic(panicNonPositiveCapacity)
+	}
+	opt := newDumpRequestOptions(options)
+	return &HarRecorder{
+		entries: make([]*harEntry, capacity),
+		opt:     opt,
+		dumper:  DumpResponse(nil, options...),
+	}
+}
+
+// harRequestKey is the gin.Context key for captured request body, used in HarRecorder.Capture and HarRecorder.Record.
+const harRequestKey = "xgin.har_request"
+
+// Capture creates a gin.HandlerFunc which captures request body and response (through DumpResponse) for HarRecorder.Record, this does not record
+// the entry, use Middleware if you want to record with its own timings.
+func (h *HarRecorder) Capture() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.opt.bodyLimit > 0 {
+			c.Set(harRequestKey, dumpRequestBody(c.Request, h.opt))
+		}
+		h.dumper(c) // c.Next() inside
+	}
+}
+
+// Middleware creates a gin.HandlerFunc which captures and records the request and response pair, using the times before and after the handlers.
+func (h *HarRecorder) Middleware() gin.HandlerFunc {
+	capture := h.Capture()
+	return func(c *gin.Context) {
+		start := time.Now()
+		capture(c)
+		h.Record(c, start, time.Now())
+	}
+}
+
+// Record records the request and response pair from gin.Context to HarRecorder using given times, which are the same as LogToLogrus's.
+// Note that request body and response body will be recorded only when HarRecorder.Capture is used.
+func (h *HarRecorder) Record(c *gin.Context, start, end time.Time) {
+	entry := h.newEntry(c, start, end)
+	h.mu.Lock()
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+	h.mu.Unlock()
+}
+
+// Len returns the count of entries in HarRecorder.
+func (h *HarRecorder) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.full {
+		return len(h.entries)
+	}
+	return h.next
+}
+
+// Reset drops all the entries in HarRecorder.
+func (h *HarRecorder) Reset() {
+	h.mu.Lock()
+	h.resetLocked()
+	h.mu.Unlock()
+}
+
+// WriteTo writes all the entries in HAR json format to given io.Writer, entries are ordered by recording time, implements io.WriterTo.
+func (h *HarRecorder) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	entries := h.entriesLocked()
+	h.mu.Unlock()
+	return writeHar(w, entries)
+}
+
+// Flush writes all the entries in HAR json format to given io.Writer, and drops these entries. Note that the entries are taken out at once, so
+// the entries recorded during writing are kept, and the taken entries will be put back before them when writing failed.
+func (h *HarRecorder) Flush(w io.Writer) error {
+	h.mu.Lock()
+	entries := h.entriesLocked()
+	h.resetLocked()
+	h.mu.Unlock()
+
+	if _, err := writeHar(w, entries); err != nil {
+		h.mu.Lock()
+		h.restoreLocked(entries)
+		h.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Handler creates a gin.HandlerFunc which responses all the entries as a HAR file.
+func (h *HarRecorder) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.har"`, time.Now().Format("20060102150405")))
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusOK)
+		_, _ = h.WriteTo(c.Writer)
+	}
+}
+
+// entriesLocked returns the entries from the ring buffer ordered by recording time, h.mu must be held by the caller.
+func (h *HarRecorder) entriesLocked() []*harEntry {
+	entries := make([]*harEntry, 0, len(h.entries))
+	if h.full {
+		entries = append(entries, h.entries[h.next:]...)
+	}
+	return append(entries, h.entries[:h.next]...)
+}
+
+// resetLocked drops all the entries in the ring buffer, h.mu must be held by the caller.
+func (h *HarRecorder) resetLocked() {
+	h.entries = make([]*harEntry, len(h.entries))
+	h.next = 0
+	h.full = false
+}
+
+// restoreLocked puts given entries back before the existing entries in the ring buffer, the oldest entries will be dropped when the buffer is
+// full, h.mu must be held by the caller.
+func (h *HarRecorder) restoreLocked(entries []*harEntry) {
+	entries = append(entries, h.entriesLocked()...)
+	if len(entries) > len(h.entries) {
+		entries = entries[len(entries)-len(h.entries):]
+	}
+	h.resetLocked()
+	copy(h.entries, entries)
+	h.next = len(entries) % len(h.entries)
+	h.full = len(entries) == len(h.entries)
+}
+
+// writeHar writes given entries in HAR json format to given io.Writer.
+func writeHar(w io.Writer, entries []*harEntry) (int64, error) {
+	bs, err := json.Marshal(&harDocument{Log: &harLog{
+		Version: "1.2",
+		Creator: &harCreator{Name: "xgin", Version: "1.0"},
+		Entries: entries,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(bs)
+	return int64(n), err
+}
+
+// newEntry creates a harEntry from given gin.Context and times.
+func (h *HarRecorder) newEntry(c *gin.Context, start, end time.Time) *harEntry {
+	req := c.Request
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	request := &harRequest{
+		Method:      req.Method,
+		URL:         scheme + "://" + req.Host + req.URL.RequestURI(),
+		HTTPVersion: req.Proto,
+		Cookies:     []*harNameValue{},
+		Headers:     splitDumpedHeaders(dumpHeaders(req.Header, h.opt)),
+		QueryString: []*harNameValue{},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range query[key] {
+			request.QueryString = append(request.QueryString, &harNameValue{Name: key, Value: value})
+		}
+	}
+	if body, ok := c.Get(harRequestKey); ok && body.(string) != "" {
+		request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: body.(string)}
+		request.BodySize = int(req.ContentLength) // maybe -1
+	}
+
+	response := &harResponse{
+		Status:      c.Writer.Status(),
+		StatusText:  http.StatusText(c.Writer.Status()),
+		HTTPVersion: req.Proto,
+		Cookies:     []*harNameValue{},
+		Content:     &harContent{Size: c.Writer.Size(), MimeType: c.Writer.Header().Get("Content-Type")},
+		RedirectURL: c.Writer.Header().Get("Location"),
+		HeadersSize: -1,
+		BodySize:    c.Writer.Size(),
+	}
+	if response.Content.Size < 0 {
+		response.Content.Size = 0
+		response.BodySize = 0
+	}
+	if dumped, ok := GetDumpedResponse(c); ok {
+		response.Headers = splitDumpedHeaders(dumped.Headers)
+		response.Content.Text = dumped.Body
+	} else {
+		response.Headers = splitDumpedHeaders(dumpHeaders(c.Writer.Header(), h.opt))
+	}
+
+	latency := float64(end.Sub(start)) / float64(time.Millisecond)
+	return &harEntry{
+		StartedDateTime: start.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            latency,
+		Request:         request,
+		Response:        response,
+		Cache:           &struct{}{},
+		Timings:         &harTimings{Send: 0, Wait: latency, Receive: 0},
+	}
+}
+
+// splitDumpedHeaders splits given "XXX: YYY" headers to harNameValue slice.
+func splitDumpedHeaders(headers []string) []*harNameValue {
+	out := make([]*harNameValue, 0, len(headers))
+	for _, header := range headers {
+		kv := strings.SplitN(header, ": ", 2)
+		if len(kv) == 2 {
+			out = append(out, &harNameValue{Name: kv[0], Value: kv[1]})
+		}
+	}
+	return out
+}
+
+// harDocument represents the root object of HAR, see http://www.softwareishard.com/blog/har-12-spec/#log.
+type harDocument struct {
+	Log *harLog `json:"log"`
+}
+
+// harLog represents the log object of HAR.
+type harLog struct {
+	Version string      `json:"version"`
+	Creator *harCreator `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+// harCreator represents the creator object of HAR.
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// harEntry represents an entry object of HAR, which is a request and response pair.
+type harEntry struct {
+	StartedDateTime string       `json:"startedDateTime"`
+	Time            float64      `json:"time"`
+	Request         *harRequest  `json:"request"`
+	Response        *harResponse `json:"response"`
+	Cache           *struct{}    `json:"cache"`
+	Timings         *harTimings  `json:"timings"`
+}
+
+// harRequest represents the request object of HAR.
+type harRequest struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	QueryString []*harNameValue `json:"queryString"`
+	PostData    *harPostData    `json:"postData,omitempty"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+// harResponse represents the response object of HAR.
+type harResponse struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*harNameValue `json:"cookies"`
+	Headers     []*harNameValue `json:"headers"`
+	Content     *harContent     `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+// harNameValue represents a name-value pair object of HAR, used in headers, cookies and query string.
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harPostData represents the postData object of HAR.
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// harContent represents the content object of HAR.
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+// harTimings represents the timings object of HAR, only wait is recorded.
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
@@ -0,0 +1,162 @@
+package xgin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type errorWriter struct{}
+
+func (e errorWriter) Write([]byte) (int, error) {
+	return 0, errors.New("test error")
+}
+
+type funcWriter func([]byte) (int, error)
+
+func (f funcWriter) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestHarRecorder(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	xtesting.PanicWithValue(t, panicNonPositiveCapacity, func() { NewHarRecorder(0) })
+
+	har := NewHarRecorder(2, WithSecretHeaders("Authorization"), WithSecretFields("password"), WithBodyLimit(1024))
+	app := gin.New()
+	app.Use(har.Middleware())
+	app.POST("/login", func(c *gin.Context) {
+		c.Header("X-Token", "token")
+		c.JSON(201, gin.H{"password": "xxx", "ok": true})
+	})
+	app.GET("/ping", func(c *gin.Context) {
+		c.String(200, "pong")
+	})
+
+	do := func(method, url, body string) {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	parse := func() map[string]interface{} {
+		buf := &bytes.Buffer{}
+		_, err := har.WriteTo(buf)
+		xtesting.Nil(t, err)
+		m := make(map[string]interface{})
+		xtesting.Nil(t, json.Unmarshal(buf.Bytes(), &m))
+		return m["log"].(map[string]interface{})
+	}
+
+	xtesting.Equal(t, har.Len(), 0)
+	xtesting.Equal(t, len(parse()["entries"].([]interface{})), 0)
+	do("POST", "/login?b=2&a=1", `{"username": "a", "password": "b"}`)
+	xtesting.Equal(t, har.Len(), 1)
+
+	log := parse()
+	xtesting.Equal(t, log["version"], "1.2")
+	entries := log["entries"].([]interface{})
+	xtesting.Equal(t, len(entries), 1)
+	entry := entries[0].(map[string]interface{})
+	request := entry["request"].(map[string]interface{})
+	xtesting.Equal(t, request["method"], "POST")
+	xtesting.Equal(t, request["url"], "http://example.com/login?b=2&a=1")
+	xtesting.Equal(t, request["headers"], []interface{}{
+		map[string]interface{}{"name": "Authorization", "value": "*"},
+		map[string]interface{}{"name": "Content-Type", "value": "application/json"},
+	})
+	xtesting.Equal(t, request["queryString"], []interface{}{
+		map[string]interface{}{"name": "a", "value": "1"},
+		map[string]interface{}{"name": "b", "value": "2"},
+	})
+	xtesting.Equal(t, request["postData"], map[string]interface{}{"mimeType": "application/json", "text": `{"username": "a", "password": "*"}`})
+	response := entry["response"].(map[string]interface{})
+	xtesting.Equal(t, response["status"], 201.0)
+	xtesting.Equal(t, response["statusText"], "Created")
+	xtesting.Equal(t, response["content"].(map[string]interface{})["text"], `{"ok":true,"password":"*"}`)
+	xtesting.Equal(t, response["headers"], []interface{}{
+		map[string]interface{}{"name": "Content-Type", "value": "application/json; charset=utf-8"},
+		map[string]interface{}{"name": "X-Token", "value": "token"},
+	})
+	xtesting.True(t, entry["time"].(float64) >= 0)
+
+	// ring buffer
+	do("GET", "/ping", "")
+	do("GET", "/ping?x=1", "")
+	xtesting.Equal(t, har.Len(), 2)
+	entries = parse()["entries"].([]interface{})
+	xtesting.Equal(t, entries[0].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping")
+	xtesting.Equal(t, entries[1].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=1")
+	do("GET", "/ping?x=2", "")
+	entries = parse()["entries"].([]interface{})
+	xtesting.Equal(t, entries[0].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=1")
+	xtesting.Equal(t, entries[1].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=2")
+
+	// flush and handler
+	xtesting.NotNil(t, har.Flush(&errorWriter{}))
+	xtesting.Equal(t, har.Len(), 2)
+	xtesting.Nil(t, har.Flush(&bytes.Buffer{}))
+	xtesting.Equal(t, har.Len(), 0)
+
+	// record during flushing
+	do("GET", "/ping?x=3", "")
+	flushed := &bytes.Buffer{}
+	xtesting.Nil(t, har.Flush(funcWriter(func(p []byte) (int, error) {
+		do("GET", "/ping?x=4", "")
+		return flushed.Write(p)
+	})))
+	xtesting.True(t, strings.Contains(flushed.String(), "x=3") && !strings.Contains(flushed.String(), "x=4"))
+	entries = parse()["entries"].([]interface{})
+	xtesting.Equal(t, len(entries), 1)
+	xtesting.Equal(t, entries[0].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=4")
+	xtesting.NotNil(t, har.Flush(funcWriter(func([]byte) (int, error) {
+		do("GET", "/ping?x=5", "")
+		return 0, errors.New("test error")
+	})))
+	entries = parse()["entries"].([]interface{})
+	xtesting.Equal(t, len(entries), 2) // x=4 is put back before x=5
+	xtesting.Equal(t, entries[0].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=4")
+	xtesting.Equal(t, entries[1].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=5")
+	xtesting.NotNil(t, har.Flush(funcWriter(func([]byte) (int, error) {
+		do("GET", "/ping?x=6", "")
+		return 0, errors.New("test error")
+	})))
+	entries = parse()["entries"].([]interface{})
+	xtesting.Equal(t, len(entries), 2) // x=4 is dropped
+	xtesting.Equal(t, entries[0].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=5")
+	xtesting.Equal(t, entries[1].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=6")
+	do("GET", "/ping?x=7", "")
+	entries = parse()["entries"].([]interface{})
+	xtesting.Equal(t, entries[1].(map[string]interface{})["request"].(map[string]interface{})["url"], "http://example.com/ping?x=7")
+	har.Reset()
+	do("GET", "/ping", "")
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	har.Handler()(ctx)
+	xtesting.Equal(t, w.Code, 200)
+	xtesting.True(t, strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment; filename="))
+	xtesting.True(t, strings.Contains(w.Body.String(), `"url":"http://example.com/ping"`))
+
+	// record with given times
+	har2 := NewHarRecorder(10)
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	app2 := gin.New()
+	app2.Use(func(c *gin.Context) {
+		c.Next()
+		har2.Record(c, start, start.Add(1500*time.Microsecond))
+	})
+	app2.GET("", func(c *gin.Context) { c.String(200, "ok") })
+	app2.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	buf := &bytes.Buffer{}
+	_, _ = har2.WriteTo(buf)
+	xtesting.True(t, strings.Contains(buf.String(), `"startedDateTime":"2021-01-01T00:00:00.000Z","time":1.5`))
+	xtesting.True(t, strings.Contains(buf.String(), `"timings":{"send":0,"wait":1.5,"receive":0}`))
+}
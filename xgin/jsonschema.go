@@ -0,0 +1,390 @@
+package xgin
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONSchema represents a JSON Schema (draft 2020-12) document or subschema, which is generated by GenerateJSONSchema.
+type JSONSchema struct {
+	Schema      string        `json:"$schema,omitempty"`
+	Ref         string        `json:"$ref,omitempty"`
+	Title       string        `json:"title,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Type        string        `json:"type,omitempty"`
+	Format      string        `json:"format,omitempty"`
+	Pattern     string        `json:"pattern,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Default     interface{}   `json:"default,omitempty"`
+
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int     `json:"minLength,omitempty"`
+	MaxLength        *int     `json:"maxLength,omitempty"`
+	MinItems         *int     `json:"minItems,omitempty"`
+	MaxItems         *int     `json:"maxItems,omitempty"`
+	UniqueItems      bool     `json:"uniqueItems,omitempty"`
+	MinProperties    *int     `json:"minProperties,omitempty"`
+	MaxProperties    *int     `json:"maxProperties,omitempty"`
+
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// jsonSchemaDraft is the JSON Schema dialect used by GenerateJSONSchema.
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// GenerateJSONSchema generates a JSON Schema document from given binding struct (or its pointer), which can be used to publish request
+// contracts. The property names are from the `json`, `form`, `uri` or `header` tags (in priority order, just like ParseBindError), and the
+// `binding` tags are mapped to schema keywords, the named nested structs are put into "$defs". Supported binding tags:
+// 	required                  -> required
+// 	min, max, len             -> minLength/maxLength, minimum/maximum, minItems/maxItems or minProperties/maxProperties
+// 	gt, gte, lt, lte          -> the same as above, exclusiveMinimum/exclusiveMaximum for numbers
+// 	eq, oneof                 -> enum
+// 	unique                    -> uniqueItems
+// 	dive                      -> the remaining tags are applied to array items
+// 	email, url, uri, uuid     -> format, such as "email", "uri" and "uuid"
+// 	ip, ipv4, ipv6            -> anyOf format "ipv4" and "ipv6" for ip, format "ipv4" and "ipv6" for the others
+// 	regexp, date, datetime    -> pattern, format "date" and format "date-time", which are the custom bindings in this package
+// 	semver, hexcolor_ext      -> pattern, which are the custom bindings in this package
+// 	hexcolor                  -> pattern, which is the same as validator's builtin one
+// The `default` tag used by ApplyDefaults is mapped to "default".
+//
+// Example:
+// 	type CreateUserRequest struct {
+// 		Name     string `json:"name"     binding:"required,min=2,max=20"`
+// 		Birthday string `json:"birthday" binding:"omitempty,date"`
+// 	}
+// 	schema := xgin.GenerateJSONSchema(&CreateUserRequest{})
+// 	bs, _ := json.MarshalIndent(schema, "", "  ")
+func GenerateJSONSchema(obj interface{}) *JSONSchema {
+	g := newJSONSchemaGenerator("#/$defs/")
+	schema := g.generate(reflect.TypeOf(obj), true)
+	schema.Schema = jsonSchemaDraft
+	if len(g.defs) > 0 {
+		schema.Defs = g.defs
+	}
+	return schema
+}
+
+// jsonSchemaGenerator is used to generate JSONSchema, which stores the named struct schemas in defs.
+type jsonSchemaGenerator struct {
+	refPrefix string                  // such as "#/$defs/"
+	defs      map[string]*JSONSchema  // definition name -> schema
+	names     map[reflect.Type]string // struct type -> definition name
+}
+
+// newJSONSchemaGenerator creates a jsonSchemaGenerator with given ref prefix.
+func newJSONSchemaGenerator(refPrefix string) *jsonSchemaGenerator {
+	return &jsonSchemaGenerator{refPrefix: refPrefix, defs: make(map[string]*JSONSchema), names: make(map[reflect.Type]string)}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawJSONType   = reflect.TypeOf(json.RawMessage{})
+	byteSliceType = reflect.TypeOf([]byte{})
+)
+
+// generate generates the JSONSchema for given type, named struct types will be referenced by "$ref" except the root type.
+func (g *jsonSchemaGenerator) generate(typ reflect.Type, root bool) *JSONSchema {
+	if typ == nil {
+		return &JSONSchema{}
+	}
+	typ = indirectType(typ)
+	switch {
+	case typ == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case typ == rawJSONType:
+		return &JSONSchema{}
+	case typ == byteSliceType:
+		return &JSONSchema{Type: "string", Format: "byte"} // base64
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &JSONSchema{Type: "integer", Minimum: float64Ptr(0)}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: g.generate(typ.Elem(), false)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.generate(typ.Elem(), false)}
+	case reflect.Struct:
+		if root || typ.Name() == "" {
+			return g.generateStruct(typ)
+		}
+		name, ok := g.names[typ]
+		if !ok {
+			name = g.defName(typ)
+			g.names[typ] = name
+			g.defs[name] = &JSONSchema{} // placeholder for recursive type
+			*g.defs[name] = *g.generateStruct(typ)
+		}
+		return &JSONSchema{Ref: g.refPrefix + name}
+	default:
+		return &JSONSchema{} // interface and others
+	}
+}
+
+// defName returns an unique definition name for given named struct type.
+func (g *jsonSchemaGenerator) defName(typ reflect.Type) string {
+	name := typ.Name()
+	if _, ok := g.defs[name]; !ok {
+		return name
+	}
+	for i := 2; ; i++ {
+		if n := name + strconv.Itoa(i); g.defs[n] == nil {
+			return n
+		}
+	}
+}
+
+// generateStruct generates the object JSONSchema for given struct type, embedded structs without tag will be flattened.
+func (g *jsonSchemaGenerator) generateStruct(typ reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	g.addStructFields(schema, typ)
+	return schema
+}
+
+// addStructFields adds the properties and required fields of given struct type to schema.
+func (g *jsonSchemaGenerator) addStructFields(schema *JSONSchema, typ reflect.Type) {
+	for i := 0; i < typ.NumField(); i++ {
+		g.addStructField(schema, typ.Field(i))
+	}
+}
+
+// addStructField adds the property of given struct field to schema, embedded structs without tag will be flattened.
+func (g *jsonSchemaGenerator) addStructField(schema *JSONSchema, field reflect.StructField) {
+	name, ok := fieldNameByTags(field)
+	if !ok {
+		return
+	}
+	if ft := indirectType(field.Type); field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+		g.addStructFields(schema, ft) // embedded struct
+		return
+	}
+	if field.PkgPath != "" {
+		return // unexported
+	}
+	if name == "" {
+		name = field.Name
+	}
+
+	property := g.generate(field.Type, false)
+	if applyBindingTag(property, field.Type, field.Tag.Get("binding")) {
+		schema.Required = append(schema.Required, name)
+	}
+	applyDefaultTag(property, field.Tag.Get("default"))
+	schema.Properties[name] = property
+}
+
+// fieldNameByTags returns the field name from bindFieldTags (in priority order), returns false if the field has no name but is ignored by
+// "-" tag, and returns empty name if there is no tag.
+func fieldNameByTags(field reflect.StructField) (string, bool) {
+	ignored := false
+	for _, tag := range bindFieldTags {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name == "-" {
+			ignored = true
+		} else if name != "" {
+			return name, true
+		}
+	}
+	return "", !ignored
+}
+
+// applyBindingTag applies given binding tag to schema of given type, and returns true if the field is required. Note that only the required
+// rule is meaningful for the "$ref" schema of struct.
+func applyBindingTag(schema *JSONSchema, typ reflect.Type, tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	typ = indirectType(typ)
+
+	required := false
+	rules := strings.Split(tag, ",")
+	for idx, rule := range rules {
+		if strings.Contains(rule, "|") {
+			continue // or rules are not supported
+		}
+		name, param := rule, ""
+		if sep := strings.Index(rule, "="); sep != -1 {
+			name, param = rule[:sep], strings.ReplaceAll(rule[sep+1:], "0x2C", ",")
+		}
+		if name == "dive" {
+			if schema.Items != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+				applyBindingTag(schema.Items, typ.Elem(), strings.Join(rules[idx+1:], ","))
+			}
+			break
+		}
+		if name == "required" {
+			required = true
+			continue
+		}
+		applyBindingRule(schema, typ, name, param)
+	}
+	return required
+}
+
+// applyDefaultTag sets the default value of schema from given `default` tag, see ApplyDefaults.
+func applyDefaultTag(schema *JSONSchema, tag string) {
+	if tag == "" {
+		return
+	}
+	if schema.Type == "array" && schema.Items != nil {
+		values := make([]interface{}, 0)
+		for _, elem := range strings.Split(tag, ",") {
+			if v, ok := parseEnumValue(schema.Items.Type, strings.ReplaceAll(elem, "0x2C", ",")); ok {
+				values = append(values, v)
+			}
+		}
+		schema.Default = values
+	} else if v, ok := parseEnumValue(schema.Type, tag); ok {
+		schema.Default = v
+	}
+}
+
+// builtinHexColorPattern is the pattern of validator's builtin `hexcolor`, which only allows "#rgb" and "#rrggbb".
+const builtinHexColorPattern = `^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`
+
+// applyBindingRule applies given binding rule to schema of given type.
+func applyBindingRule(schema *JSONSchema, typ reflect.Type, name, param string) {
+	switch name {
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
+		applyRangeRule(schema, name, param)
+	case "eq":
+		if v, ok := parseEnumValue(schema.Type, param); ok {
+			schema.Enum = []interface{}{v}
+		}
+	case "oneof":
+		for _, p := range strings.Fields(param) {
+			if v, ok := parseEnumValue(schema.Type, p); ok {
+				schema.Enum = append(schema.Enum, v)
+			}
+		}
+	case "unique":
+		schema.UniqueItems = schema.Type == "array"
+	case "email", "uuid", "ipv4", "ipv6", "hostname":
+		schema.Format = name
+	case "ip":
+		schema.AnyOf = []*JSONSchema{{Format: "ipv4"}, {Format: "ipv6"}}
+	case "url", "uri":
+		schema.Format = "uri"
+	case "uuid3", "uuid4", "uuid5", "uuid_rfc4122", "uuid3_rfc4122", "uuid4_rfc4122", "uuid5_rfc4122":
+		schema.Format = "uuid"
+	case "regexp":
+		schema.Pattern = param
+	case "date":
+		schema.Format = "date"
+	case "datetime":
+		if param == "" { // builtin datetime has layout param
+			schema.Format = "date-time"
+		}
+	case "semver":
+		schema.Pattern = semverRegexp.String()
+	case "hexcolor":
+		schema.Pattern = builtinHexColorPattern
+	case "hexcolor_ext":
+		schema.Pattern = hexColorRegexp.String()
+	}
+}
+
+// applyRangeRule applies given range rule (min, max, len, gt, gte, lt and lte) to schema, according to the schema type.
+func applyRangeRule(schema *JSONSchema, name, param string) {
+	switch schema.Type {
+	case "integer", "number":
+		v, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		switch name {
+		case "min", "gte":
+			schema.Minimum = &v
+		case "max", "lte":
+			schema.Maximum = &v
+		case "len":
+			schema.Minimum, schema.Maximum = &v, float64Ptr(v)
+		case "gt":
+			schema.ExclusiveMinimum = &v
+		case "lt":
+			schema.ExclusiveMaximum = &v
+		}
+	case "string", "array", "object":
+		v, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		var min, max **int
+		switch schema.Type {
+		case "string":
+			min, max = &schema.MinLength, &schema.MaxLength
+		case "array":
+			min, max = &schema.MinItems, &schema.MaxItems
+		default:
+			min, max = &schema.MinProperties, &schema.MaxProperties
+		}
+		switch name {
+		case "min", "gte":
+			*min = intPtr(v)
+		case "max", "lte":
+			*max = intPtr(v)
+		case "len":
+			*min, *max = intPtr(v), intPtr(v)
+		case "gt":
+			*min = intPtr(v + 1)
+		case "lt":
+			*max = intPtr(v - 1)
+		}
+	}
+}
+
+// parseEnumValue parses given enum value according to the schema type.
+func parseEnumValue(typ, value string) (interface{}, bool) {
+	switch typ {
+	case "integer":
+		v, err := strconv.ParseInt(value, 10, 64)
+		return v, err == nil
+	case "number":
+		v, err := strconv.ParseFloat(value, 64)
+		return v, err == nil
+	case "boolean":
+		v, err := strconv.ParseBool(value)
+		return v, err == nil
+	case "string":
+		return value, true
+	}
+	return nil, false
+}
+
+// indirectType returns the element type of given pointer type, or itself if it is not a pointer.
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// float64Ptr returns the pointer of given float64.
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+// intPtr returns the pointer of given int.
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,139 @@
+package xgin
+
+import (
+	"encoding/json"
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"testing"
+	"time"
+)
+
+type schemaBase struct {
+	ID uint64 `json:"id" binding:"required"`
+}
+
+type schemaAddress struct {
+	City    string         `json:"city" binding:"required,max=32"`
+	Parent  *schemaAddress `json:"parent"`
+	private string
+}
+
+type schemaRequest struct {
+	schemaBase
+	Name      string            `json:"name"      binding:"required,min=2,max=20"`
+	Code      string            `json:"code"      binding:"len=6,regexp=^[0-9]+$"`
+	Gender    string            `json:"gender"    binding:"omitempty,oneof=male female"`
+	Level     int               `json:"level"     binding:"oneof=1 2 3"`
+	Score     float64           `json:"score"     binding:"gt=0,lte=100"`
+	Age       uint8             `form:"age"       binding:"gte=18,lt=150"`
+	Email     *string           `json:"email"     binding:"required,email"`
+	Homepage  string            `json:"homepage"  binding:"omitempty,url"`
+	Birthday  string            `json:"birthday"  binding:"date"`
+	CreatedAt string            `json:"createdAt" binding:"datetime"`
+	Layout    string            `json:"layout"    binding:"datetime=2006-01-02"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Version   string            `json:"version"   binding:"semver"`
+	Color     string            `json:"color"     binding:"hexcolor_ext"`
+	Host      string            `json:"host"      binding:"ip"`
+	Builtin   string            `json:"builtin"   binding:"hexcolor"`
+	Enabled   bool              `json:"enabled"   binding:"eq=true"`
+	Tags      []string          `json:"tags"      binding:"required,min=1,unique,dive,max=10,oneof=a b"`
+	Emails    []string          `json:"emails"    binding:"dive,email"`
+	Matrix    [][]int           `json:"matrix"`
+	Address   *schemaAddress    `json:"address"   binding:"required"`
+	Addresses []schemaAddress   `json:"addresses" binding:"max=3"`
+	Extra     map[string]string `json:"extra"     binding:"max=5"`
+	Inline    struct {
+		A string `json:"a" binding:"required"`
+	} `json:"inline"`
+	Any     interface{}     `json:"any"`
+	Raw     json.RawMessage `json:"raw"`
+	Bytes   []byte          `json:"bytes"`
+	NoTag   string          `binding:"required,min=x"`
+	Ignored string          `json:"-"`
+	Or      string          `json:"or" binding:"email|url"`
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	for _, give := range []interface{}{schemaRequest{}, &schemaRequest{}} {
+		bs, err := json.Marshal(GenerateJSONSchema(give))
+		xtesting.Nil(t, err)
+		var got, want interface{}
+		xtesting.Nil(t, json.Unmarshal(bs, &got))
+		xtesting.Nil(t, json.Unmarshal([]byte(`{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"properties": {
+		"id": {"type": "integer", "minimum": 0},
+		"name": {"type": "string", "minLength": 2, "maxLength": 20},
+		"code": {"type": "string", "minLength": 6, "maxLength": 6, "pattern": "^[0-9]+$"},
+		"gender": {"type": "string", "enum": ["male", "female"]},
+		"level": {"type": "integer", "enum": [1, 2, 3]},
+		"score": {"type": "number", "exclusiveMinimum": 0, "maximum": 100},
+		"age": {"type": "integer", "minimum": 18, "exclusiveMaximum": 150},
+		"email": {"type": "string", "format": "email"},
+		"homepage": {"type": "string", "format": "uri"},
+		"birthday": {"type": "string", "format": "date"},
+		"createdAt": {"type": "string", "format": "date-time"},
+		"layout": {"type": "string"},
+		"updatedAt": {"type": "string", "format": "date-time"},
+		"version": {"type": "string", "pattern": "` + jsonEscape(semverRegexp.String()) + `"},
+		"color": {"type": "string", "pattern": "` + jsonEscape(hexColorRegexp.String()) + `"},
+		"host": {"type": "string", "anyOf": [{"format": "ipv4"}, {"format": "ipv6"}]},
+		"builtin": {"type": "string", "pattern": "^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$"},
+		"enabled": {"type": "boolean", "enum": [true]},
+		"tags": {"type": "array", "minItems": 1, "uniqueItems": true, "items": {"type": "string", "maxLength": 10, "enum": ["a", "b"]}},
+		"emails": {"type": "array", "items": {"type": "string", "format": "email"}},
+		"matrix": {"type": "array", "items": {"type": "array", "items": {"type": "integer"}}},
+		"address": {"$ref": "#/$defs/schemaAddress"},
+		"addresses": {"type": "array", "maxItems": 3, "items": {"$ref": "#/$defs/schemaAddress"}},
+		"extra": {"type": "object", "maxProperties": 5, "additionalProperties": {"type": "string"}},
+		"inline": {"type": "object", "properties": {"a": {"type": "string"}}, "required": ["a"]},
+		"any": {},
+		"raw": {},
+		"bytes": {"type": "string", "format": "byte"},
+		"NoTag": {"type": "string"},
+		"or": {"type": "string"}
+	},
+	"required": ["id", "name", "email", "tags", "address", "NoTag"],
+	"$defs": {
+		"schemaAddress": {
+			"type": "object",
+			"properties": {
+				"city": {"type": "string", "maxLength": 32},
+				"parent": {"$ref": "#/$defs/schemaAddress"}
+			},
+			"required": ["city"]
+		}
+	}
+}`), &want))
+		xtesting.Equal(t, got, want)
+	}
+
+	// others
+	xtesting.Equal(t, GenerateJSONSchema(nil), &JSONSchema{Schema: jsonSchemaDraft})
+	xtesting.Equal(t, GenerateJSONSchema([]int{}), &JSONSchema{Schema: jsonSchemaDraft, Type: "array", Items: &JSONSchema{Type: "integer"}})
+
+	type schemaAddress struct { // the same name with different type
+		Street string `json:"street"`
+	}
+	type twoAddresses struct {
+		A *schemaAddress `json:"a"`
+		B schemaBase     `json:"b"`
+		C *schemaBase    `json:"c"`
+		D *schemaRequest `json:"d"`
+	}
+	schema := GenerateJSONSchema(&twoAddresses{})
+	xtesting.Equal(t, schema.Properties["a"].Ref, "#/$defs/schemaAddress")
+	xtesting.Equal(t, schema.Properties["b"].Ref, "#/$defs/schemaBase")
+	xtesting.Equal(t, schema.Properties["c"].Ref, "#/$defs/schemaBase")
+	xtesting.Equal(t, schema.Properties["d"].Ref, "#/$defs/schemaRequest")
+	xtesting.Equal(t, schema.Defs["schemaRequest"].Properties["address"].Ref, "#/$defs/schemaAddress2")
+	xtesting.Equal(t, schema.Defs["schemaAddress"].Properties["street"].Type, "string")
+	xtesting.Equal(t, schema.Defs["schemaAddress2"].Properties["city"].Type, "string")
+	xtesting.Equal(t, len(schema.Defs), 4)
+}
+
+func jsonEscape(s string) string {
+	bs, _ := json.Marshal(s)
+	return string(bs[1 : len(bs)-1])
+}
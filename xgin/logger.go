@@ -1,11 +1,14 @@
 package xgin
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Aoi-hosizora/ahlib-web/internal/logopt"
 	"github.com/Aoi-hosizora/ahlib/xnumber"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
+	"reflect"
 	"strings"
 	"time"
 )
@@ -25,6 +28,19 @@ func WithExtraFieldsV(fields ...interface{}) logopt.LoggerOption {
 	return logopt.WithExtraFieldsV(fields...)
 }
 
+// WithDumpedResponse creates a logger option to log with the DumpedResponse stored in gin.Context as extra fields, see DumpResponse.
+// Fields "resp_headers" and "resp_body" will be added, and nothing will be added if DumpResponse is not used.
+func WithDumpedResponse(c *gin.Context) logopt.LoggerOption {
+	resp, ok := GetDumpedResponse(c)
+	if !ok {
+		return nil
+	}
+	return logopt.WithMergedFields(map[string]interface{}{
+		"resp_headers": resp.Headers,
+		"resp_body":    resp.Body,
+	})
+}
+
 // loggerParam stores some logger parameters, used in LogToLogrus and LogToLogger.
 type loggerParam struct {
 	method       string
@@ -36,6 +52,8 @@ type loggerParam struct {
 	length       int
 	clientIP     string
 	contextError string
+
+	validationErrors []*ValidationFailure
 }
 
 // getLoggerParamAndFields returns loggerParam and logrus.Fields from given gin.Context and times.
@@ -62,6 +80,7 @@ func getLoggerParamAndFields(c *gin.Context, start, end time.Time) (*loggerParam
 		clientIP:     c.ClientIP(),
 		contextError: errorMessage,
 	}
+	param.validationErrors, _ = GetValidationFailures(c)
 	fields := logrus.Fields{
 		"module":     "gin",
 		"method":     param.method,
@@ -74,6 +93,9 @@ func getLoggerParamAndFields(c *gin.Context, start, end time.Time) (*loggerParam
 		"client_ip":  param.clientIP,
 		"ctx_error":  param.contextError,
 	}
+	if len(param.validationErrors) > 0 {
+		fields["validation_errors"] = param.validationErrors
+	}
 	return param, fields
 }
 
@@ -111,11 +133,205 @@ func LogToLogger(logger logrus.StdLogger, c *gin.Context, start, end time.Time,
 // 	[Gin]      200 |      993.3µs |             ::1 |        11B | GET     /test
 // 	     |--------| |------------| |---------------| |----------| |-------|-----|
 // 	         8            12               15             10          7     ...
+// 	[Gin]      400 |      993.3µs |             ::1 |        11B | POST    /test | (ctx error) | validation: name(required), age(min=18)
 func formatLogger(param *loggerParam) string {
 	msg := fmt.Sprintf("[Gin] %8d | %12s | %15s | %10s | %-7s %s",
 		param.status, param.latency.String(), param.clientIP, xnumber.RenderByte(float64(param.length)), param.method, param.path)
 	if param.contextError != "" {
 		msg = fmt.Sprintf("%s | (%s)", msg, param.contextError)
 	}
+	if len(param.validationErrors) > 0 {
+		failures := make([]string, 0, len(param.validationErrors))
+		for _, f := range param.validationErrors {
+			rule := f.Tag
+			if f.Param != "" {
+				rule += "=" + f.Param
+			}
+			failures = append(failures, fmt.Sprintf("%s(%s)", f.Field, rule))
+		}
+		msg = fmt.Sprintf("%s | validation: %s", msg, strings.Join(failures, ", "))
+	}
 	return msg
 }
+
+// =====================
+// validation failures
+// =====================
+
+// ValidationFailure represents a validation failure recorded in gin.Context by RecordValidationErrors, which will be logged by LogToLogrus
+// as "validation_errors" field.
+type ValidationFailure struct {
+	Field string `json:"field"` // field path from struct tags, such as "user.emails[0]"
+	Tag   string `json:"tag"`   // failed validation tag, such as "required"
+	Param string `json:"param"` // validation tag's param, such as "10" in "max=10"
+	Value string `json:"value"` // summary of the failed value, which is redacted to type and length unless it is a plain field, empty for BindError
+}
+
+// validationLogOptions represents some options for RecordValidationErrors, set by ValidationLogOption.
+type validationLogOptions struct {
+	secretFields  []string
+	secretReplace string
+	plainFields   []string
+}
+
+// ValidationLogOption represents an option for RecordValidationErrors, can be created by WithValidationXXX functions. Note that the fields
+// are matched case-insensitively by the full path (such as "user.password") or the last field name (such as "password").
+type ValidationLogOption func(*validationLogOptions)
+
+// WithValidationSecretFields creates a ValidationLogOption for secret fields, such as password and token, these fields' values will always be
+// replaced by the string set by WithValidationSecretReplace.
+func WithValidationSecretFields(fields ...string) ValidationLogOption {
+	return func(o *validationLogOptions) {
+		o.secretFields = fields
+	}
+}
+
+// WithValidationSecretReplace creates a ValidationLogOption for secret fields' replace string, defaults to "*".
+func WithValidationSecretReplace(secret string) ValidationLogOption {
+	return func(o *validationLogOptions) {
+		o.secretReplace = secret
+	}
+}
+
+// WithValidationPlainFields creates a ValidationLogOption for plain fields, only these fields' failed values will be logged in summary, such
+// as "abc" and 18, the values of other fields are redacted to their types and lengths by default.
+func WithValidationPlainFields(fields ...string) ValidationLogOption {
+	return func(o *validationLogOptions) {
+		o.plainFields = fields
+	}
+}
+
+// newValidationLogOptions creates a validationLogOptions from given ValidationLogOption-s.
+func newValidationLogOptions(options []ValidationLogOption) *validationLogOptions {
+	opt := &validationLogOptions{
+		secretReplace: "*",
+	}
+	for _, op := range options {
+		if op != nil {
+			op(opt)
+		}
+	}
+	return opt
+}
+
+// validationFailuresKey is the gin.Context key for ValidationFailure-s, used in RecordValidationErrors and GetValidationFailures.
+const validationFailuresKey = "xgin.validation_failures"
+
+// validationValueLimit is the max rune count of value summary in ValidationFailure.
+const validationValueLimit = 32
+
+// RecordValidationErrors records the validation failures of given bind error to gin.Context, which will be logged by LogToLogrus and
+// LogToLogger, the bind error can be validator.ValidationErrors (or the error wrapping it) and BindError. The field paths are generated
+// in the same way as ParseBindError. The values are redacted to their types and lengths by default, such as string(len=3), int and
+// []string(len=3), only the values of fields given by WithValidationPlainFields are summarized, such as "abc" and 18, and the values of
+// fields given by WithValidationSecretFields will always be replaced. Note that BindError does not hold the failed values, so the values
+// are always empty for the BindError which does not wrap validator.ValidationErrors.
+//
+// Example:
+// 	req := &LoginRequest{}
+// 	if err := c.ShouldBindJSON(req); err != nil {
+// 		xgin.RecordValidationErrors(c, err, req, xgin.WithValidationPlainFields("username"), xgin.WithValidationSecretFields("password"))
+// 		...
+// 	}
+// 	// [Gin]      400 |      993.3µs |             ::1 |        11B | POST    /login | validation: password(min=8)
+func RecordValidationErrors(c *gin.Context, err error, obj interface{}, options ...ValidationLogOption) {
+	opt := newValidationLogOptions(options)
+	failures := make([]*ValidationFailure, 0)
+	var ve validator.ValidationErrors
+	var be *BindError
+	switch {
+	case errors.As(err, &ve):
+		typ := reflect.TypeOf(obj)
+		for _, fe := range ve {
+			field := fieldPathByTags(typ, fe.StructNamespace())
+			value := redactValue(fe.Value())
+			if matchFieldPath(field, opt.secretFields) {
+				value = opt.secretReplace
+			} else if matchFieldPath(field, opt.plainFields) {
+				value = summarizeValue(fe.Value())
+			}
+			failures = append(failures, &ValidationFailure{Field: field, Tag: fe.Tag(), Param: fe.Param(), Value: value})
+		}
+	case errors.As(err, &be):
+		for _, fe := range be.Fields {
+			if fe.Tag != "" {
+				failures = append(failures, &ValidationFailure{Field: fe.Field, Tag: fe.Tag, Param: fe.Param})
+			}
+		}
+	}
+	if len(failures) == 0 {
+		return
+	}
+
+	if existed, ok := GetValidationFailures(c); ok {
+		failures = append(existed, failures...)
+	}
+	c.Set(validationFailuresKey, failures)
+}
+
+// GetValidationFailures returns the ValidationFailure-s recorded in gin.Context by RecordValidationErrors.
+func GetValidationFailures(c *gin.Context) ([]*ValidationFailure, bool) {
+	v, ok := c.Get(validationFailuresKey)
+	if !ok {
+		return nil, false
+	}
+	failures, ok := v.([]*ValidationFailure)
+	return failures, ok
+}
+
+// matchFieldPath checks whether given field path matches one of the given fields, by the full path or the last field name.
+func matchFieldPath(field string, fields []string) bool {
+	name := field[strings.LastIndex(field, ".")+1:]
+	if idx := strings.Index(name, "["); idx != -1 {
+		name = name[:idx]
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f, field) || strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue returns the redacted summary of given value, which only contains its type, and length for string and collections.
+func redactValue(value interface{}) string {
+	val := reflect.ValueOf(value)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if !val.IsValid() || (val.Kind() == reflect.Ptr && val.IsNil()) {
+		return "<nil>"
+	}
+
+	switch val.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("%s(len=%d)", val.Type().String(), val.Len())
+	default:
+		return val.Type().String()
+	}
+}
+
+// summarizeValue returns the summary of given value, long strings are truncated, and collections are replaced by their types and lengths.
+func summarizeValue(value interface{}) string {
+	val := reflect.ValueOf(value)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if !val.IsValid() || (val.Kind() == reflect.Ptr && val.IsNil()) {
+		return "<nil>"
+	}
+
+	var summary string
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("%s(len=%d)", val.Type().String(), val.Len())
+	case reflect.String:
+		summary = val.String()
+	default:
+		summary = fmt.Sprintf("%v", val.Interface())
+	}
+	if runes := []rune(summary); len(runes) > validationValueLimit {
+		summary = string(runes[:validationValueLimit]) + "..."
+	}
+	return summary
+}
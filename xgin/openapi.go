@@ -0,0 +1,443 @@
+package xgin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RouteDoc represents the document of a route registered to AppRouter, which is used to generate OpenAPI document, see AppRouter.Describe.
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	OperationID string
+	Deprecated  bool
+
+	// Request is the binding struct (or its pointer) of the route, fields with `uri`, `header` and `form` tags will be documented as path,
+	// header and query parameters, and the others will be documented as the json request body for POST, PUT and PATCH methods.
+	Request interface{}
+
+	// Response is the json response value of the route, such as &User{} and []*User{}, and ResponseStatus defaults to 200.
+	Response       interface{}
+	ResponseStatus int
+}
+
+const (
+	panicRouteNotFound = "xgin: route '%s /%s' is not found"
+)
+
+// Describe attaches given RouteDoc to the route with given method and relativePath, the route should be added to AppRouter first, and
+// this method panics if the route is not found.
+//
+// Example:
+// 	ar.GET("users/:id", getUser)
+// 	ar.Describe(http.MethodGet, "users/:id", &xgin.RouteDoc{Summary: "Get user", Request: &GetUserRequest{}, Response: &User{}})
+func (a *AppRouter) Describe(method, relativePath string, doc *RouteDoc) {
+	relativePath = strings.Trim(relativePath, "/")
+	for _, routers := range a.groups {
+		if routers[0].method != method {
+			continue
+		}
+		for _, router := range routers {
+			if router.relativePath == relativePath {
+				router.doc = doc
+				return
+			}
+		}
+	}
+	panic(fmt.Sprintf(panicRouteNotFound, method, relativePath))
+}
+
+// OpenAPIDocument represents an OpenAPI 3.1 document, which is generated by AppRouter.GenerateOpenAPI. Note that the schemas in OpenAPI
+// 3.1 are JSON Schema (draft 2020-12), so JSONSchema is used directly.
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       *OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"` // path -> lower case method -> operation
+	Components *OpenAPIComponents                      `json:"components,omitempty"`
+}
+
+// OpenAPIInfo represents the info object of OpenAPIDocument.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIOperation represents the operation object of OpenAPIDocument, which is generated from a route and its RouteDoc.
+type OpenAPIOperation struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	OperationID string                      `json:"operationId,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+}
+
+// OpenAPIParameter represents the parameter object of OpenAPIOperation, In is one of "path", "query" and "header".
+type OpenAPIParameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *JSONSchema `json:"schema"`
+}
+
+// OpenAPIRequestBody represents the request body object of OpenAPIOperation.
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse represents the response object of OpenAPIOperation.
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType represents the media type object of OpenAPIRequestBody and OpenAPIResponse.
+type OpenAPIMediaType struct {
+	Schema *JSONSchema `json:"schema"`
+}
+
+// OpenAPIComponents represents the components object of OpenAPIDocument, the named structs are put into Schemas.
+type OpenAPIComponents struct {
+	Schemas map[string]*JSONSchema `json:"schemas"`
+}
+
+// openAPIVersion is the OpenAPI version used by AppRouter.GenerateOpenAPI.
+const openAPIVersion = "3.1.0"
+
+// GenerateOpenAPI generates an OpenAPI 3.1 document from all the routes added to AppRouter and their RouteDoc, the routes without RouteDoc
+// are also included, with the path parameters only. The binding structs are converted in the same way as GenerateJSONSchema, and the named
+// structs are put into "#/components/schemas/".
+//
+// Example:
+// 	doc := ar.GenerateOpenAPI(&xgin.OpenAPIInfo{Title: "demo", Version: "1.0.0"})
+// 	bs, _ := doc.YAML()
+func (a *AppRouter) GenerateOpenAPI(info *OpenAPIInfo) *OpenAPIDocument {
+	if info == nil {
+		info = &OpenAPIInfo{}
+	}
+	basePath := a.basePath()
+
+	g := newJSONSchemaGenerator("#/components/schemas/")
+	doc := &OpenAPIDocument{OpenAPI: openAPIVersion, Info: info, Paths: make(map[string]map[string]*OpenAPIOperation)}
+	for _, routers := range a.groups {
+		for _, router := range routers {
+			path := openAPIPath(basePath, router.layerNames)
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*OpenAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(router.method)] = g.generateOperation(router)
+		}
+	}
+	if len(g.defs) > 0 {
+		doc.Components = &OpenAPIComponents{Schemas: g.defs}
+	}
+	return doc
+}
+
+// openAPIPath joins given base path and layer names, and converts gin's parameters to OpenAPI's, such as "/v1/users/:id" to
+// "/v1/users/{id}".
+func openAPIPath(basePath string, layerNames []string) string {
+	segments := make([]string, 0, len(layerNames)+1)
+	if basePath = strings.Trim(basePath, "/"); basePath != "" {
+		segments = append(segments, basePath)
+	}
+	for _, name := range layerNames {
+		if strings.HasPrefix(name, ":") || strings.HasPrefix(name, "*") {
+			name, _ = splitParamLayer(name)
+			name = "{" + name + "}"
+		}
+		segments = append(segments, name)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// constraintSchema returns the JSONSchema for given parameter constraint, such as {"type": "integer"} for "int".
+func constraintSchema(constraint string) *JSONSchema {
+	switch constraint {
+	case "":
+		return &JSONSchema{Type: "string"}
+	case "int":
+		return &JSONSchema{Type: "integer"}
+	case "uuid", "date":
+		return &JSONSchema{Type: "string", Format: constraint}
+	}
+	return &JSONSchema{Type: "string", Pattern: "^(?:" + constraint + ")$"}
+}
+
+// generateOperation generates the OpenAPIOperation for given router, using its RouteDoc.
+func (g *jsonSchemaGenerator) generateOperation(router *routerConfig) *OpenAPIOperation {
+	doc := router.doc
+	if doc == nil {
+		doc = &RouteDoc{}
+	}
+	op := &OpenAPIOperation{
+		Tags: doc.Tags, Summary: doc.Summary, Description: doc.Description,
+		OperationID: doc.OperationID, Deprecated: doc.Deprecated,
+	}
+
+	// parameters
+	var params []*OpenAPIParameter
+	var bodyFields []reflect.StructField
+	if doc.Request != nil {
+		params, bodyFields = g.generateParameters(indirectType(reflect.TypeOf(doc.Request)), router.method)
+	}
+	pathParams := make(map[string]*OpenAPIParameter)
+	for _, param := range params {
+		if param.In == "path" {
+			pathParams[param.Name] = param
+		}
+	}
+	for _, layerName := range router.layerNames {
+		if strings.HasPrefix(layerName, ":") || strings.HasPrefix(layerName, "*") {
+			name, constraint := splitParamLayer(layerName)
+			param, ok := pathParams[name]
+			if !ok {
+				param = &OpenAPIParameter{Name: name, In: "path", Schema: constraintSchema(constraint)}
+			}
+			param.Required = true
+			op.Parameters = append(op.Parameters, param)
+		}
+	}
+	for _, param := range params {
+		if param.In != "path" {
+			op.Parameters = append(op.Parameters, param)
+		}
+	}
+
+	// request body
+	if len(bodyFields) > 0 {
+		var schema *JSONSchema
+		if typ := indirectType(reflect.TypeOf(doc.Request)); len(params) == 0 && typ.Name() != "" {
+			schema = g.generate(typ, false) // use $ref if there is no parameter
+		} else {
+			schema = &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+			for _, field := range bodyFields {
+				g.addStructField(schema, field)
+			}
+		}
+		op.RequestBody = &OpenAPIRequestBody{Required: true, Content: map[string]*OpenAPIMediaType{"application/json": {Schema: schema}}}
+	}
+
+	// responses
+	status := doc.ResponseStatus
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := &OpenAPIResponse{Description: http.StatusText(status)}
+	if doc.Response != nil {
+		response.Content = map[string]*OpenAPIMediaType{"application/json": {Schema: g.generate(reflect.TypeOf(doc.Response), false)}}
+	}
+	op.Responses = map[string]*OpenAPIResponse{strconv.Itoa(status): response}
+	return op
+}
+
+// generateParameters generates the path, query and header parameters from given struct type, and returns the remaining fields for the json
+// request body, note that the request body is only used for POST, PUT and PATCH methods.
+func (g *jsonSchemaGenerator) generateParameters(typ reflect.Type, method string) ([]*OpenAPIParameter, []reflect.StructField) {
+	if typ.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	hasBody := method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+	params := make([]*OpenAPIParameter, 0)
+	bodyFields := make([]reflect.StructField, 0)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		jsonName := tagName(field, "json")
+		if field.Anonymous && jsonName == "" && tagName(field, "form") == "" && tagName(field, "uri") == "" {
+			if ft := indirectType(field.Type); ft.Kind() == reflect.Struct {
+				subParams, subFields := g.generateParameters(ft, method) // embedded struct
+				params = append(params, subParams...)
+				bodyFields = append(bodyFields, subFields...)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		var name, in string
+		if name = tagName(field, "uri"); name != "" {
+			in = "path"
+		} else if name = tagName(field, "header"); name != "" {
+			in = "header"
+		} else if name = tagName(field, "form"); name != "" && (jsonName == "" || !hasBody) {
+			in = "query"
+		} else {
+			if hasBody && jsonName != "-" {
+				bodyFields = append(bodyFields, field)
+			}
+			continue
+		}
+		schema := g.generate(field.Type, false)
+		required := applyBindingTag(schema, field.Type, field.Tag.Get("binding"))
+		applyDefaultTag(schema, field.Tag.Get("default"))
+		params = append(params, &OpenAPIParameter{Name: name, In: in, Required: required, Schema: schema})
+	}
+	return params, bodyFields
+}
+
+// tagName returns the name in given tag of struct field, such as "name" for `json:"name,omitempty"`.
+func tagName(field reflect.StructField, tag string) string {
+	return strings.Split(field.Tag.Get(tag), ",")[0]
+}
+
+// JSON returns the indented json encoding of the document.
+func (d *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML returns the yaml encoding of the document, the fields are in the same order as JSON.
+func (d *OpenAPIDocument) YAML() ([]byte, error) {
+	bs, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(bs)
+}
+
+// OpenAPIWrap adds routes serving the OpenAPI document of AppRouter to given gin.IRouter, using given prefix (defaults to "/docs"), info and
+// guard handlers. The document is generated for each request, so the routes added after calling this function are also included.
+//
+// Routes:
+// 	GET /docs/openapi.json // document in json
+// 	GET /docs/openapi.yaml // document in yaml
+func (a *AppRouter) OpenAPIWrap(router gin.IRouter, prefix string, info *OpenAPIInfo, guards ...gin.HandlerFunc) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		prefix = "/docs"
+	}
+	serve := func(contentType string, encode func(*OpenAPIDocument) ([]byte, error)) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			bs, err := encode(a.GenerateOpenAPI(info))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+				return
+			}
+			c.Data(http.StatusOK, contentType, bs)
+		}
+	}
+
+	group := router.Group(prefix, guards...)
+	group.GET("/openapi.json", serve("application/json; charset=utf-8", (*OpenAPIDocument).JSON))
+	group.GET("/openapi.yaml", serve("application/yaml; charset=utf-8", (*OpenAPIDocument).YAML))
+}
+
+// yamlNode represents a json value decoded in order, which is used by jsonToYAML.
+type yamlNode struct {
+	keys   []string    // for object
+	values []*yamlNode // for object and array
+	isObj  bool
+	isArr  bool
+	scalar string // for others, in yaml format
+}
+
+// jsonToYAML converts given json bytes to yaml in block style, and keeps the order of object keys.
+func jsonToYAML(bs []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+	node, err := decodeYAMLNode(decoder)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if node.isObj || node.isArr {
+		writeYAMLNode(buf, node, 0)
+	} else {
+		buf.WriteString(node.scalar + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeYAMLNode decodes the next json value from decoder to yamlNode.
+func decodeYAMLNode(decoder *json.Decoder) (*yamlNode, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := token.(type) {
+	case json.Delim: // '{' or '['
+		node := &yamlNode{isObj: v == '{', isArr: v == '['}
+		for decoder.More() {
+			if node.isObj {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			value, err := decodeYAMLNode(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, value)
+		}
+		if _, err = decoder.Token(); err != nil { // '}' or ']'
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yamlNode{scalar: yamlString(v)}, nil
+	case json.Number:
+		return &yamlNode{scalar: v.String()}, nil
+	case bool:
+		return &yamlNode{scalar: strconv.FormatBool(v)}, nil
+	default: // nil
+		return &yamlNode{scalar: "null"}, nil
+	}
+}
+
+// writeYAMLNode writes given object or array yamlNode to buf with given indent.
+func writeYAMLNode(buf *bytes.Buffer, node *yamlNode, indent int) {
+	spaces := strings.Repeat(" ", indent)
+	for idx, value := range node.values {
+		if node.isObj {
+			buf.WriteString(spaces + yamlString(node.keys[idx]) + ":")
+		} else {
+			buf.WriteString(spaces + "-")
+		}
+		switch {
+		case value.isObj && len(value.values) == 0:
+			buf.WriteString(" {}\n")
+		case value.isArr && len(value.values) == 0:
+			buf.WriteString(" []\n")
+		case value.isObj || value.isArr:
+			if node.isObj {
+				buf.WriteString("\n")
+				writeYAMLNode(buf, value, indent+2)
+			} else {
+				sub := &bytes.Buffer{}
+				writeYAMLNode(sub, value, indent+2)
+				buf.WriteString(" " + strings.TrimPrefix(sub.String(), spaces+"  ")) // "- key: value" or "- - value"
+			}
+		default:
+			buf.WriteString(" " + value.scalar + "\n")
+		}
+	}
+}
+
+// yamlPlainRegexp matches the strings which can be written in yaml plain style.
+var yamlPlainRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_ ./()-]*$`)
+
+// yamlString returns the yaml representation of given string, which is quoted if it can not be written in plain style.
+func yamlString(s string) string {
+	if yamlPlainRegexp.MatchString(s) && !strings.HasSuffix(s, " ") {
+		switch strings.ToLower(s) {
+		case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		default:
+			return s
+		}
+	}
+	return strconv.Quote(s)
+}
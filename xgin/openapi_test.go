@@ -0,0 +1,217 @@
+package xgin
+
+import (
+	"encoding/json"
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type openAPIUser struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+type openAPIPagination struct {
+	Page  int32 `form:"page"  binding:"omitempty,min=1"`
+	Limit int32 `form:"limit" binding:"omitempty,max=50"`
+}
+
+type openAPIListRequest struct {
+	openAPIPagination
+	Token string `header:"X-Token" binding:"required"`
+}
+
+type openAPIGetRequest struct {
+	ID uint64 `uri:"id" binding:"required,min=1"`
+}
+
+type openAPICreateRequest struct {
+	Name   string `json:"name" binding:"required,max=20"`
+	Silent bool   `form:"silent"`
+}
+
+type openAPIUpdateRequest struct {
+	ID     uint64 `uri:"id"`
+	Name   string `json:"name" binding:"required"`
+	Hidden string `json:"-"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	fn := func(*gin.Context) {}
+	ar := NewAppRouter(app, app.Group("v1"))
+	ar.GET("users", fn)
+	ar.GET("users/:id", fn)
+	ar.GET("users/:id/files/*path", fn)
+	ar.POST("users", fn)
+	ar.PUT("users/:id", fn)
+	ar.DELETE("users/:id", fn)
+	ar.POST("login", fn)
+
+	xtesting.Panic(t, func() { ar.Describe(http.MethodGet, "users/:uid", &RouteDoc{}) })
+	xtesting.Panic(t, func() { ar.Describe(http.MethodPatch, "users/:id", &RouteDoc{}) })
+	ar.Describe(http.MethodGet, "/users/", &RouteDoc{Summary: "List users", Tags: []string{"user"}, Request: &openAPIListRequest{}, Response: []*openAPIUser{}})
+	ar.Describe(http.MethodGet, "users/:id", &RouteDoc{Summary: "Get user", OperationID: "getUser", Request: openAPIGetRequest{}, Response: &openAPIUser{}})
+	ar.Describe(http.MethodPost, "users", &RouteDoc{Summary: "Create user", Request: &openAPICreateRequest{}, Response: &openAPIUser{}, ResponseStatus: 201})
+	ar.Describe(http.MethodPut, "users/:id", &RouteDoc{Description: "Update user", Deprecated: true, Request: &openAPIUpdateRequest{}})
+	ar.Describe(http.MethodPost, "login", &RouteDoc{Request: &openAPIUser{}})
+
+	doc := ar.GenerateOpenAPI(&OpenAPIInfo{Title: "demo", Version: "1.0.0"})
+	bs, err := doc.JSON()
+	xtesting.Nil(t, err)
+	var got, want interface{}
+	xtesting.Nil(t, json.Unmarshal(bs, &got))
+	xtesting.Nil(t, json.Unmarshal([]byte(`{
+	"openapi": "3.1.0",
+	"info": {"title": "demo", "version": "1.0.0"},
+	"paths": {
+		"/v1/users": {
+			"get": {
+				"tags": ["user"],
+				"summary": "List users",
+				"parameters": [
+					{"name": "page", "in": "query", "schema": {"type": "integer", "minimum": 1}},
+					{"name": "limit", "in": "query", "schema": {"type": "integer", "maximum": 50}},
+					{"name": "X-Token", "in": "header", "required": true, "schema": {"type": "string"}}
+				],
+				"responses": {"200": {"description": "OK", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/openAPIUser"}}}}}}
+			},
+			"post": {
+				"summary": "Create user",
+				"parameters": [{"name": "silent", "in": "query", "schema": {"type": "boolean"}}],
+				"requestBody": {"required": true, "content": {"application/json": {"schema": {"type": "object", "properties": {"name": {"type": "string", "maxLength": 20}}, "required": ["name"]}}}},
+				"responses": {"201": {"description": "Created", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/openAPIUser"}}}}}
+			}
+		},
+		"/v1/users/{id}": {
+			"get": {
+				"summary": "Get user",
+				"operationId": "getUser",
+				"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}}],
+				"responses": {"200": {"description": "OK", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/openAPIUser"}}}}}
+			},
+			"put": {
+				"description": "Update user",
+				"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 0}}],
+				"requestBody": {"required": true, "content": {"application/json": {"schema": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}}}},
+				"responses": {"200": {"description": "OK"}},
+				"deprecated": true
+			},
+			"delete": {
+				"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}],
+				"responses": {"200": {"description": "OK"}}
+			}
+		},
+		"/v1/users/{id}/files/{path}": {
+			"get": {
+				"parameters": [
+					{"name": "id", "in": "path", "required": true, "schema": {"type": "string"}},
+					{"name": "path", "in": "path", "required": true, "schema": {"type": "string"}}
+				],
+				"responses": {"200": {"description": "OK"}}
+			}
+		},
+		"/v1/login": {
+			"post": {
+				"requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/openAPIUser"}}}},
+				"responses": {"200": {"description": "OK"}}
+			}
+		}
+	},
+	"components": {
+		"schemas": {
+			"openAPIUser": {"type": "object", "properties": {"id": {"type": "integer", "minimum": 0}, "name": {"type": "string"}}}
+		}
+	}
+}`), &want))
+	xtesting.Equal(t, got, want)
+
+	// empty
+	doc = NewAppRouter(app, app).GenerateOpenAPI(nil)
+	xtesting.Equal(t, doc, &OpenAPIDocument{OpenAPI: "3.1.0", Info: &OpenAPIInfo{}, Paths: map[string]map[string]*OpenAPIOperation{}})
+
+	// yaml
+	bs, err = (&OpenAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    &OpenAPIInfo{Title: "demo: api", Version: "1.0"},
+		Paths: map[string]map[string]*OpenAPIOperation{
+			"/a/{id}": {"get": {
+				Tags: []string{"yes", "a b"},
+				Parameters: []*OpenAPIParameter{
+					{Name: "id", In: "path", Required: true, Schema: &JSONSchema{Type: "string", Enum: []interface{}{"x", 1, true, nil}}},
+				},
+				Responses: map[string]*OpenAPIResponse{"200": {Description: "OK", Content: map[string]*OpenAPIMediaType{"application/json": {Schema: &JSONSchema{}}}}},
+			}},
+			"/b": {},
+		},
+	}).YAML()
+	xtesting.Nil(t, err)
+	xtesting.Equal(t, string(bs), `openapi: "3.1.0"
+info:
+  title: "demo: api"
+  version: "1.0"
+paths:
+  "/a/{id}":
+    get:
+      tags:
+        - "yes"
+        - a b
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: string
+            enum:
+              - x
+              - 1
+              - true
+              - null
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema: {}
+  "/b": {}
+`)
+	for _, tc := range []struct {
+		give string
+		want string
+	}{
+		{`[]`, ""},
+		{`"a"`, "a\n"},
+		{`[[1, 2], [], {"a": [3]}]`, "- - 1\n  - 2\n- []\n- a:\n    - 3\n"},
+		{`{"a": "line\nbreak", "b": " x", "c": "", "d": "~", "e": "null", "f": "1.5"}`, "a: \"line\\nbreak\"\nb: \" x\"\nc: \"\"\nd: \"~\"\ne: \"null\"\nf: \"1.5\"\n"},
+	} {
+		bs, err := jsonToYAML([]byte(tc.give))
+		xtesting.Nil(t, err)
+		xtesting.Equal(t, string(bs), tc.want)
+	}
+	_, err = jsonToYAML([]byte(`{"a": `))
+	xtesting.NotNil(t, err)
+
+	// wrap
+	ar.OpenAPIWrap(app, "", &OpenAPIInfo{Title: "demo", Version: "1.0.0"})
+	for _, tc := range []struct {
+		givePath        string
+		wantContentType string
+	}{
+		{"/docs/openapi.json", "application/json; charset=utf-8"},
+		{"/docs/openapi.yaml", "application/yaml; charset=utf-8"},
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest("GET", tc.givePath, nil))
+		xtesting.Equal(t, w.Code, 200)
+		xtesting.Equal(t, w.Header().Get("Content-Type"), tc.wantContentType)
+		xtesting.True(t, len(w.Body.String()) > 0)
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/docs/openapi.json", nil))
+	xtesting.Nil(t, json.Unmarshal(w.Body.Bytes(), &got))
+	xtesting.Equal(t, got, want)
+}
@@ -0,0 +1,339 @@
+package xgin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/debug"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// =====
+// pprof
+// =====
+
+// PprofWrap adds several routes from package `net/http/pprof` to gin.Engine. Reference from https://github.com/DeanThompson/ginpprof.
+// This is the same as PprofWrapRouter(router, "/debug/pprof").
+func PprofWrap(router *gin.Engine) {
+	PprofWrapRouter(router, "/debug/pprof")
+}
+
+// PprofWrapRouter adds several routes from package `net/http/pprof` to given gin.IRouter, using given prefix (defaults to "/debug/pprof")
+// and guard handlers, such as gin.BasicAuth, IPAllowlistGuard and TokenGuard. Note that the named profile routes are registered dynamically
+// from runtime/pprof.Profiles(), which means custom profiles created by runtime/pprof.NewProfile before calling this function will be added.
+//
+// Example:
+// 	admin := app.Group("/admin")
+// 	xgin.PprofWrapRouter(admin, "/pprof", xgin.IPAllowlistGuard("127.0.0.1", "10.0.0.0/8"), gin.BasicAuth(gin.Accounts{"admin": "password"}))
+// 	// GET /admin/pprof/, GET /admin/pprof/heap, ...
+func PprofWrapRouter(router gin.IRouter, prefix string, guards ...gin.HandlerFunc) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		prefix = "/debug/pprof"
+	}
+	group := router.Group(prefix, guards...)
+
+	type route struct {
+		method  string
+		path    string
+		handler gin.HandlerFunc
+	}
+	routes := []route{
+		{"GET", "/", indexHandler()},
+		{"GET", "/cmdline", cmdlineHandler()},
+		{"GET", "/profile", profileHandler()},
+		{"GET", "/symbol", symbolHandler()},
+		{"POST", "/symbol", symbolHandler()},
+		{"GET", "/trace", traceHandler()},
+	}
+	for _, p := range runtimepprof.Profiles() {
+		// heap, goroutine, allocs, block, threadcreate, mutex, and custom profiles
+		routes = append(routes, route{"GET", "/" + p.Name(), namedProfileHandler(p.Name())})
+	}
+	for _, r := range routes {
+		group.Handle(r.method, r.path, r.handler) // use path directly
+	}
+}
+
+// indexHandler is used for GET /debug/pprof to pprof.
+func indexHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		pprof.Index(ctx.Writer, ctx.Request)
+	}
+}
+
+// namedProfileHandler is used for GET /debug/pprof/xxx to pprof, such as heap, goroutine, allocs, block, threadcreate and mutex.
+func namedProfileHandler(name string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		pprof.Handler(name).ServeHTTP(ctx.Writer, ctx.Request)
+	}
+}
+
+// cmdlineHandler is used for GET /debug/pprof/cmdline to pprof.
+func cmdlineHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		pprof.Cmdline(ctx.Writer, ctx.Request)
+	}
+}
+
+// profileHandler is used for GET /debug/pprof/profile to pprof.
+func profileHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		pprof.Profile(ctx.Writer, ctx.Request)
+	}
+}
+
+// symbolHandler is used for GET, POST /debug/pprof/symbol to pprof.
+func symbolHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		pprof.Symbol(ctx.Writer, ctx.Request)
+	}
+}
+
+// traceHandler is used for GET /debug/pprof/trace to pprof.
+func traceHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		pprof.Trace(ctx.Writer, ctx.Request)
+	}
+}
+
+// ===============
+// runtime control
+// ===============
+
+var (
+	// runtimeSettingsMu guards the runtime settings reading and changing by RuntimeControlWrap.
+	runtimeSettingsMu sync.Mutex
+
+	// blockProfileRate stores the block profile rate set by RuntimeControlWrap, because it can not be read from runtime.
+	blockProfileRate int
+
+	// gcPercent stores the gc percent set by RuntimeControlWrap, because it can not be read from runtime without changing it, the initial
+	// value is parsed from GOGC environment variable in the same way as runtime.
+	gcPercent = initialGCPercent()
+)
+
+// initialGCPercent returns the initial gc percent parsed from GOGC environment variable, defaults to 100, and "off" means -1.
+func initialGCPercent() int {
+	switch s := os.Getenv("GOGC"); s {
+	case "":
+		return 100
+	case "off":
+		return -1
+	default:
+		if v, err := strconv.Atoi(s); err == nil {
+			return v
+		}
+		return 100
+	}
+}
+
+// RuntimeControlWrap adds several routes to given gin.IRouter for reading and changing runtime profiling settings, using given prefix (defaults
+// to "/debug/runtime") and guard handlers. Block profile and mutex profile in PprofWrap are empty unless their rates are set, so these routes
+// can be used to enable contention profiling without a redeploy. Note that these routes should be protected by guards in production.
+//
+// Routes:
+// 	GET  /debug/runtime/    // get settings, returns {"block_profile_rate": 0, "mutex_profile_fraction": 0, "gc_percent": 100, "num_gc": 1}
+// 	PUT  /debug/runtime/    // change settings by query, such as ?block_profile_rate=1&mutex_profile_fraction=5&gc_percent=50, returns new settings
+// 	POST /debug/runtime/gc  // run a garbage collection, returns new settings with "heap_alloc_before" and "heap_alloc_after"
+//
+// Note that the block profile rate and gc percent returned are the ones set through these routes, because runtime does not expose them.
+func RuntimeControlWrap(router gin.IRouter, prefix string, guards ...gin.HandlerFunc) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		prefix = "/debug/runtime"
+	}
+	group := router.Group(prefix, guards...)
+	group.GET("/", runtimeSettingsHandler())
+	group.PUT("/", runtimeChangeHandler())
+	group.POST("/gc", runtimeGCHandler())
+}
+
+// runtimeSettings returns the current runtime profiling settings, runtimeSettingsMu must be held by the caller.
+func runtimeSettings() gin.H {
+	stats := &runtime.MemStats{}
+	runtime.ReadMemStats(stats)
+	return gin.H{
+		"block_profile_rate":     blockProfileRate,
+		"mutex_profile_fraction": runtime.SetMutexProfileFraction(-1), // negative value for reading
+		"gc_percent":             gcPercent,
+		"num_gc":                 stats.NumGC,
+	}
+}
+
+// runtimeSettingsHandler is used for GET /debug/runtime/.
+func runtimeSettingsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		runtimeSettingsMu.Lock()
+		settings := runtimeSettings()
+		runtimeSettingsMu.Unlock()
+		c.JSON(http.StatusOK, settings)
+	}
+}
+
+// runtimeChangeHandler is used for PUT /debug/runtime/.
+func runtimeChangeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		values := make(map[string]int, 3)
+		for _, key := range []string{"block_profile_rate", "mutex_profile_fraction", "gc_percent"} {
+			if s, ok := c.GetQuery(key); ok {
+				v, err := strconv.Atoi(s)
+				if err != nil || (v < 0 && key != "gc_percent") { // negative gc_percent means disabling gc
+					c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("invalid %s '%s'", key, s)})
+					return
+				}
+				values[key] = v
+			}
+		}
+
+		runtimeSettingsMu.Lock()
+		if v, ok := values["block_profile_rate"]; ok {
+			runtime.SetBlockProfileRate(v)
+			blockProfileRate = v
+		}
+		if v, ok := values["mutex_profile_fraction"]; ok {
+			runtime.SetMutexProfileFraction(v)
+		}
+		if v, ok := values["gc_percent"]; ok {
+			debug.SetGCPercent(v)
+			gcPercent = v
+		}
+		settings := runtimeSettings()
+		runtimeSettingsMu.Unlock()
+		c.JSON(http.StatusOK, settings)
+	}
+}
+
+// runtimeGCHandler is used for POST /debug/runtime/gc.
+func runtimeGCHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats := &runtime.MemStats{}
+		runtime.ReadMemStats(stats)
+		before := stats.HeapAlloc
+		runtime.GC()
+		runtime.ReadMemStats(stats)
+
+		runtimeSettingsMu.Lock()
+		settings := runtimeSettings()
+		runtimeSettingsMu.Unlock()
+		settings["heap_alloc_before"] = before
+		settings["heap_alloc_after"] = stats.HeapAlloc
+		c.JSON(http.StatusOK, settings)
+	}
+}
+
+// ======
+// guards
+// ======
+
+const (
+	panicInvalidIPOrCIDR = "xgin: invalid ip or cidr '%s'"
+)
+
+// IPAllowlistGuard creates a gin.HandlerFunc which only allows the requests from given ips or cidrs, such as "127.0.0.1", "::1" and
+// "10.0.0.0/8", otherwise responses 403, panics when given ip or cidr is invalid. Note that only the host of http.Request's RemoteAddr is
+// checked, and the forwarded headers are ignored because they can be spoofed, use ProxiedIPAllowlistGuard when behind reverse proxies.
+func IPAllowlistGuard(ips ...string) gin.HandlerFunc {
+	return newIPAllowlistGuard(nil, ips)
+}
+
+// ProxiedIPAllowlistGuard creates a gin.HandlerFunc just like IPAllowlistGuard, but when the request comes from one of given trusted proxies
+// (ips or cidrs), the client ip is taken from "X-Forwarded-For" header (the rightmost address which is not a trusted proxy) or "X-Real-IP"
+// header instead, panics when given ip or cidr is invalid.
+//
+// Example:
+// 	xgin.PprofWrapRouter(app, "", xgin.ProxiedIPAllowlistGuard([]string{"10.0.0.1"}, "192.0.2.0/24"))
+func ProxiedIPAllowlistGuard(trustedProxies []string, ips ...string) gin.HandlerFunc {
+	return newIPAllowlistGuard(parseIPNetworks(trustedProxies), ips)
+}
+
+// newIPAllowlistGuard is the implementation of IPAllowlistGuard and ProxiedIPAllowlistGuard.
+func newIPAllowlistGuard(proxies []*net.IPNet, ips []string) gin.HandlerFunc {
+	networks := parseIPNetworks(ips)
+	return func(c *gin.Context) {
+		if ip := guardClientIP(c.Request, proxies); ip != nil && containsIP(networks, ip) {
+			return
+		}
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
+// parseIPNetworks parses given ips or cidrs to net.IPNet-s, panics when given ip or cidr is invalid.
+func parseIPNetworks(ips []string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(ips))
+	for _, ip := range ips {
+		cidr := ip
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf(panicInvalidIPOrCIDR, ip))
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// containsIP checks whether given ip is in one of given networks.
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// guardClientIP returns the client ip of given request, the forwarded headers are only used when the remote address is a trusted proxy.
+func guardClientIP(req *http.Request, proxies []*net.IPNet) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(req.RemoteAddr))
+	if err != nil {
+		host = strings.TrimSpace(req.RemoteAddr)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(proxies, ip) {
+		return ip
+	}
+
+	if forwarded := strings.Join(req.Header["X-Forwarded-For"], ","); forwarded != "" {
+		addresses := strings.Split(forwarded, ",")
+		for i := len(addresses) - 1; i >= 0; i-- {
+			ip = net.ParseIP(strings.TrimSpace(addresses[i]))
+			if ip == nil || !containsIP(proxies, ip) {
+				return ip // rightmost untrusted address
+			}
+		}
+		return ip // all addresses are trusted proxies
+	}
+	if realIP := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP
+	}
+	return ip
+}
+
+// TokenGuard creates a gin.HandlerFunc which only allows the requests with given token, otherwise responses 401. The token must be
+// passed by "Authorization: Bearer xxx" header, query is not supported because it will be logged as a part of the request path.
+func TokenGuard(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		given := ""
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			given = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if given == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package xgin
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	runtimepprof "runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProfileCollector represents a background collector which captures profiles at a fixed interval, and writes them to a directory with size-based
+// and count-based rotation. This can be used to get the profiles from just before an incident.
+type ProfileCollector struct {
+	dir string
+	opt *profileCollectorOptions
+
+	mu     sync.Mutex
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// profileCollectorOptions represents some options for ProfileCollector, set by ProfileCollectorOption.
+type profileCollectorOptions struct {
+	interval     time.Duration
+	cpuDuration  time.Duration
+	profiles     []string
+	maxCount     int
+	maxSize      int64
+	errorHandler func(err error)
+}
+
+// ProfileCollectorOption represents an option for ProfileCollector, can be created by WithXXX functions.
+type ProfileCollectorOption func(*profileCollectorOptions)
+
+// WithCollectInterval creates a ProfileCollectorOption for collecting interval, defaults to 1 minute.
+func WithCollectInterval(interval time.Duration) ProfileCollectorOption {
+	return func(o *profileCollectorOptions) {
+		o.interval = interval
+	}
+}
+
+// WithCPUProfileDuration creates a ProfileCollectorOption for the duration of each cpu profile, defaults to 10 seconds.
+func WithCPUProfileDuration(duration time.Duration) ProfileCollectorOption {
+	return func(o *profileCollectorOptions) {
+		o.cpuDuration = duration
+	}
+}
+
+// WithCollectProfiles creates a ProfileCollectorOption for the profiles to be collected, defaults to "cpu", "heap", "goroutine" and "mutex".
+// Here "cpu" means the cpu profile, and other names are the named profiles in runtime/pprof, such as "allocs", "block" and custom profiles.
+func WithCollectProfiles(profiles ...string) ProfileCollectorOption {
+	return func(o *profileCollectorOptions) {
+		o.profiles = profiles
+	}
+}
+
+// WithMaxProfileCount creates a ProfileCollectorOption for the max count of profile files, defaults to 100, the oldest files will be removed
+// when exceeded, and zero or negative value means no limit.
+func WithMaxProfileCount(count int) ProfileCollectorOption {
+	return func(o *profileCollectorOptions) {
+		o.maxCount = count
+	}
+}
+
+// WithMaxProfileSize creates a ProfileCollectorOption for the max total size in bytes of profile files, defaults to 100MB, the oldest files will be
+// removed when exceeded, and zero or negative value means no limit.
+func WithMaxProfileSize(size int64) ProfileCollectorOption {
+	return func(o *profileCollectorOptions) {
+		o.maxSize = size
+	}
+}
+
+// WithCollectErrorHandler creates a ProfileCollectorOption for handling errors in background collecting, errors will be ignored by default.
+func WithCollectErrorHandler(handler func(err error)) ProfileCollectorOption {
+	return func(o *profileCollectorOptions) {
+		o.errorHandler = handler
+	}
+}
+
+// NewProfileCollector creates a ProfileCollector with given directory and ProfileCollectorOption-s, use ProfileCollector.Start to start collecting.
+//
+// Example:
+// 	collector := xgin.NewProfileCollector("./profiles", xgin.WithCollectInterval(time.Minute), xgin.WithMaxProfileCount(200))
+// 	_ = collector.Start()
+// 	defer collector.Stop()
+// 	collector.Wrap(app, "/debug/profiles", xgin.TokenGuard("xxx"))
+func NewProfileCollector(dir string, options ...ProfileCollectorOption) *ProfileCollector {
+	opt := &profileCollectorOptions{
+		interval:    time.Minute,
+		cpuDuration: 10 * time.Second,
+		profiles:    []string{"cpu", "heap", "goroutine", "mutex"},
+		maxCount:    100,
+		maxSize:     100 << 20,
+	}
+	for _, op := range options {
+		if op != nil {
+			op(opt)
+		}
+	}
+	return &ProfileCollector{dir: dir, opt: opt}
+}
+
+var (
+	errCollectorStarted    = errors.New("xgin: profile collector has already been started")
+	errNonPositiveInterval = errors.New("xgin: non-positive collecting interval")
+)
+
+// Start creates the directory and starts collecting in background, returns error when the collector has already been started.
+func (p *ProfileCollector) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopCh != nil {
+		return errCollectorStarted
+	}
+	if p.opt.interval <= 0 {
+		return errNonPositiveInterval
+	}
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return err
+	}
+
+	p.stopCh = make(chan struct{})
+	p.doneCh = make(chan struct{})
+	go p.loop(p.stopCh, p.doneCh)
+	return nil
+}
+
+// Stop stops collecting and waits for the background goroutine to exit, does nothing if the collector is not started.
+func (p *ProfileCollector) Stop() {
+	p.mu.Lock()
+	stopCh, doneCh := p.stopCh, p.doneCh
+	p.stopCh, p.doneCh = nil, nil
+	p.mu.Unlock()
+
+	if stopCh != nil {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// loop is the background collecting loop, used in ProfileCollector.Start.
+func (p *ProfileCollector) loop(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+	ticker := time.NewTicker(p.opt.interval)
+	defer ticker.Stop()
+	for {
+		p.collect(stopCh)
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect captures all the profiles once and does rotation, returns the first error.
+func (p *ProfileCollector) Collect() error {
+	return p.collect(nil)
+}
+
+// collect is the implementation of ProfileCollector.Collect, cpu profiling will be interrupted when stopCh is closed.
+func (p *ProfileCollector) collect(stopCh chan struct{}) error {
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return p.handleError(err)
+	}
+
+	var firstErr error
+	timestamp := time.Now().Format(profileTimeLayout) // the same timestamp for all the profiles in a round
+	for _, name := range p.opt.profiles {
+		if err := p.writeProfile(name, timestamp, stopCh); err != nil {
+			p.handleError(err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if err := p.rotate(); err != nil {
+		p.handleError(err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// handleError invokes the error handler if set, and returns the error directly.
+func (p *ProfileCollector) handleError(err error) error {
+	if p.opt.errorHandler != nil {
+		p.opt.errorHandler(err)
+	}
+	return err
+}
+
+const (
+	profileTimeLayout = "20060102T150405.000"
+	profileFileSuffix = ".pb.gz"
+)
+
+// writeProfile writes the given profile to a file named "name-timestamp.pb.gz", the file will be removed if failed to write the profile.
+func (p *ProfileCollector) writeProfile(name, timestamp string, stopCh chan struct{}) error {
+	var profile *runtimepprof.Profile
+	if name != "cpu" {
+		if profile = runtimepprof.Lookup(name); profile == nil {
+			return fmt.Errorf("xgin: unknown profile '%s'", name)
+		}
+	}
+
+	filename := filepath.Join(p.dir, name+"-"+timestamp+profileFileSuffix)
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = p.writeProfileTo(f, profile, stopCh); err == nil {
+		err = f.Close()
+	} else {
+		_ = f.Close()
+	}
+	if err != nil {
+		_ = os.Remove(filename) // empty or broken file should not be rotated as a profile
+		return err
+	}
+	return nil
+}
+
+// writeProfileTo writes the given profile to the file, or writes the cpu profile when profile is nil.
+func (p *ProfileCollector) writeProfileTo(f *os.File, profile *runtimepprof.Profile, stopCh chan struct{}) error {
+	if profile != nil {
+		return profile.WriteTo(f, 0)
+	}
+	if err := runtimepprof.StartCPUProfile(f); err != nil {
+		return err // cpu profiling may be already in use
+	}
+	timer := time.NewTimer(p.opt.cpuDuration)
+	select {
+	case <-stopCh: // nil channel blocks forever
+	case <-timer.C:
+	}
+	timer.Stop()
+	runtimepprof.StopCPUProfile()
+	return nil
+}
+
+// profileFile represents a profile file in ProfileCollector's directory.
+type profileFile struct {
+	Name      string    `json:"name"`
+	Profile   string    `json:"profile"`
+	Size      int64     `json:"size"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// files returns all the profile files in directory, sorted by timestamp and name.
+func (p *ProfileCollector) files() ([]*profileFile, error) {
+	infos, err := ioutil.ReadDir(p.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*profileFile, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, profileFileSuffix) {
+			continue
+		}
+		sep := strings.LastIndex(name, "-")
+		if sep == -1 {
+			continue
+		}
+		timestamp, err := time.ParseInLocation(profileTimeLayout, strings.TrimSuffix(name[sep+1:], profileFileSuffix), time.Local)
+		if err != nil {
+			continue
+		}
+		files = append(files, &profileFile{Name: name, Profile: name[:sep], Size: info.Size(), Timestamp: timestamp})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].Timestamp.Equal(files[j].Timestamp) {
+			return files[i].Timestamp.Before(files[j].Timestamp)
+		}
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// rotate removes the oldest files until the count and total size are in the limits.
+func (p *ProfileCollector) rotate() error {
+	files, err := p.files()
+	if err != nil {
+		return err
+	}
+	total := int64(0)
+	for _, file := range files {
+		total += file.Size
+	}
+
+	for len(files) > 0 && ((p.opt.maxCount > 0 && len(files) > p.opt.maxCount) || (p.opt.maxSize > 0 && total > p.opt.maxSize)) {
+		if err = os.Remove(filepath.Join(p.dir, files[0].Name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= files[0].Size
+		files = files[1:]
+	}
+	return nil
+}
+
+// Wrap adds routes for listing and downloading profile files to given gin.IRouter, using given prefix (defaults to "/debug/profiles") and
+// guard handlers. Note that these routes should be protected by guards in production.
+//
+// Routes:
+// 	GET /debug/profiles/       // list files, returns [{"name": "heap-20210101T000000.000.pb.gz", "profile": "heap", "size": 1024, "timestamp": "..."}]
+// 	GET /debug/profiles/:name  // download file, which can be used by `go tool pprof`
+func (p *ProfileCollector) Wrap(router gin.IRouter, prefix string, guards ...gin.HandlerFunc) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		prefix = "/debug/profiles"
+	}
+	group := router.Group(prefix, guards...)
+	group.GET("/", func(c *gin.Context) {
+		files, err := p.files()
+		if err != nil && !os.IsNotExist(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if files == nil {
+			files = []*profileFile{}
+		}
+		c.JSON(http.StatusOK, files)
+	})
+	group.GET("/:name", func(c *gin.Context) {
+		name := c.Param("name")
+		if name != filepath.Base(name) || !strings.HasSuffix(name, profileFileSuffix) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "profile not found"})
+			return
+		}
+		path := filepath.Join(p.dir, name)
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			c.JSON(http.StatusNotFound, gin.H{"message": "profile not found"})
+			return
+		}
+		c.FileAttachment(path, name)
+	})
+}
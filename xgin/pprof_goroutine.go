@@ -0,0 +1,193 @@
+package xgin
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	runtimepprof "runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// goroutineSnapshot represents a goroutine profile snapshot, which counts goroutines by stack signature.
+type goroutineSnapshot struct {
+	name   string
+	time   time.Time
+	total  int
+	counts map[string]int // signature -> count
+}
+
+// takeGoroutineSnapshot takes a goroutineSnapshot from the current goroutine profile with given name.
+func takeGoroutineSnapshot(name string) *goroutineSnapshot {
+	buf := &bytes.Buffer{}
+	_ = runtimepprof.Lookup("goroutine").WriteTo(buf, 1) // ignore error
+	counts := parseGoroutineProfile(buf.String())
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return &goroutineSnapshot{name: name, time: time.Now(), total: total, counts: counts}
+}
+
+// parseGoroutineProfile parses goroutine profile in debug=1 format, and returns the goroutine counts grouped by stack signature.
+// The profile looks like:
+// 	goroutine profile: total 3
+// 	2 @ 0x43a8c5 0x4071ba 0x406f7b 0x6c5f4b 0x46f8e1
+// 	#	0x6c5f4a	main.worker+0x2a	/path/to/main.go:12
+//
+// 	1 @ 0x43a8c5 0x44a0ef 0x46f8e1
+// 	#	0x44a0ee	main.main+0x2e		/path/to/main.go:20
+//
+// And the signature is the joined function names and file lines, such as "main.worker /path/to/main.go:12".
+func parseGoroutineProfile(profile string) map[string]int {
+	counts := make(map[string]int)
+	count := 0
+	frames := make([]string, 0)
+	flush := func() {
+		if count > 0 {
+			counts[strings.Join(frames, "\n")] += count
+		}
+		count = 0
+		frames = frames[:0]
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(profile))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#"):
+			// #	0x6c5f4a	main.worker+0x2a	/path/to/main.go:12
+			fields := strings.Fields(strings.TrimPrefix(line, "#"))
+			if len(fields) >= 3 {
+				fn := fields[1]
+				if idx := strings.LastIndex(fn, "+0x"); idx != -1 {
+					fn = fn[:idx]
+				}
+				frames = append(frames, fn+" "+fields[len(fields)-1])
+			}
+		case strings.Contains(line, " @ "):
+			// 2 @ 0x43a8c5 0x4071ba
+			flush()
+			count, _ = strconv.Atoi(line[:strings.Index(line, " @ ")])
+		}
+	}
+	flush()
+	return counts
+}
+
+// goroutineDiff represents a goroutine count difference of a stack signature between two snapshots.
+type goroutineDiff struct {
+	Signature string `json:"signature"`
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	Delta     int    `json:"delta"`
+}
+
+// diffGoroutineSnapshots returns the differences between two goroutineSnapshot-s, which are sorted by delta descending, and signatures with
+// no difference are not included.
+func diffGoroutineSnapshots(from, to *goroutineSnapshot) []*goroutineDiff {
+	diffs := make([]*goroutineDiff, 0)
+	for signature, toCount := range to.counts {
+		if fromCount := from.counts[signature]; fromCount != toCount {
+			diffs = append(diffs, &goroutineDiff{Signature: signature, From: fromCount, To: toCount, Delta: toCount - fromCount})
+		}
+	}
+	for signature, fromCount := range from.counts {
+		if _, ok := to.counts[signature]; !ok {
+			diffs = append(diffs, &goroutineDiff{Signature: signature, From: fromCount, To: 0, Delta: -fromCount})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Delta != diffs[j].Delta {
+			return diffs[i].Delta > diffs[j].Delta
+		}
+		return diffs[i].Signature < diffs[j].Signature
+	})
+	return diffs
+}
+
+// GoroutineDiffWrap adds several routes for goroutine leak hunting to given gin.IRouter, using given prefix (defaults to "/debug/goroutines")
+// and guard handlers. These routes store named goroutine profile snapshots in memory, and return the differences between two of them, grouped by
+// stack signature with counts. Note that these routes should be protected by guards in production.
+//
+// Routes:
+// 	GET    /debug/goroutines/snapshots        // list snapshots, returns [{"name": "a", "time": "...", "total": 10}]
+// 	POST   /debug/goroutines/snapshots/:name  // take a snapshot with given name, overwrites the existing one
+// 	DELETE /debug/goroutines/snapshots/:name  // delete the snapshot with given name
+// 	GET    /debug/goroutines/diff?from=a&to=b // diff between two snapshots, "to" can be omitted to use the current goroutines
+//
+// The diff result looks like:
+// 	{"from": "a", "to": "b", "from_total": 10, "to_total": 12, "diffs": [{"signature": "main.worker /path/to/main.go:12\n...", "from": 0, "to": 2, "delta": 2}]}
+func GoroutineDiffWrap(router gin.IRouter, prefix string, guards ...gin.HandlerFunc) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" {
+		prefix = "/debug/goroutines"
+	}
+	mu := sync.Mutex{}
+	snapshots := make(map[string]*goroutineSnapshot)
+	notFound := func(c *gin.Context, name string) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "snapshot '" + name + "' not found"})
+	}
+
+	group := router.Group(prefix, guards...)
+	group.GET("/snapshots", func(c *gin.Context) {
+		mu.Lock()
+		list := make([]gin.H, 0, len(snapshots))
+		for _, s := range snapshots {
+			list = append(list, gin.H{"name": s.name, "time": s.time.Format(time.RFC3339), "total": s.total})
+		}
+		mu.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i]["name"].(string) < list[j]["name"].(string) })
+		c.JSON(http.StatusOK, list)
+	})
+	group.POST("/snapshots/:name", func(c *gin.Context) {
+		s := takeGoroutineSnapshot(c.Param("name"))
+		mu.Lock()
+		snapshots[s.name] = s
+		mu.Unlock()
+		c.JSON(http.StatusOK, gin.H{"name": s.name, "time": s.time.Format(time.RFC3339), "total": s.total})
+	})
+	group.DELETE("/snapshots/:name", func(c *gin.Context) {
+		name := c.Param("name")
+		mu.Lock()
+		_, ok := snapshots[name]
+		delete(snapshots, name)
+		mu.Unlock()
+		if !ok {
+			notFound(c, name)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"name": name})
+	})
+	group.GET("/diff", func(c *gin.Context) {
+		fromName, toName := c.Query("from"), c.Query("to")
+		mu.Lock()
+		from, fromOk := snapshots[fromName]
+		to, toOk := snapshots[toName]
+		mu.Unlock()
+		if !fromOk {
+			notFound(c, fromName)
+			return
+		}
+		if toName == "" {
+			to = takeGoroutineSnapshot("")
+		} else if !toOk {
+			notFound(c, toName)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"from":       from.name,
+			"to":         to.name,
+			"from_total": from.total,
+			"to_total":   to.total,
+			"diffs":      diffGoroutineSnapshots(from, to),
+		})
+	})
+}
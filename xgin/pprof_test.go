@@ -0,0 +1,393 @@
+package xgin
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPprofWrap(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	PprofWrap(app)
+	server := &http.Server{Addr: ":12345", Handler: app}
+	go server.ListenAndServe()
+	defer server.Shutdown(context.Background())
+
+	for _, tc := range []struct {
+		giveMethod string
+		giveUrl    string
+	}{
+		{"GET", "debug/pprof/"},
+		{"GET", "debug/pprof/heap"},
+		{"GET", "debug/pprof/goroutine"},
+		{"GET", "debug/pprof/allocs"},
+		{"GET", "debug/pprof/block"},
+		{"GET", "debug/pprof/threadcreate"},
+		{"GET", "debug/pprof/cmdline"},
+		// {"GET", "debug/pprof/profile"}, // <<< too slow
+		{"GET", "debug/pprof/symbol"},
+		{"POST", "debug/pprof/symbol"},
+		{"GET", "debug/pprof/trace"},
+		{"GET", "debug/pprof/mutex"},
+	} {
+		var resp *http.Response
+		var err error
+		url := "http://localhost:12345/" + tc.giveUrl
+		if tc.giveMethod == http.MethodGet {
+			resp, err = http.Get(url)
+		} else if tc.giveMethod == http.MethodPost {
+			resp, err = http.Post(url, "application/json", nil)
+		}
+		xtesting.Nil(t, err)
+		if err == nil {
+			xtesting.Equal(t, resp.StatusCode, 200)
+		}
+	}
+
+	// for slow debug/pprof/profile
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://localhost:12345/debug/pprof/profile", nil) // after go113
+	client := &http.Client{}
+	_, _ = client.Do(req) // ignore result
+}
+
+func TestPprofWrapRouter(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	if runtimepprof.Lookup("xgin_custom") == nil {
+		runtimepprof.NewProfile("xgin_custom") // custom profile
+	}
+
+	app := gin.New()
+	admin := app.Group("admin")
+	PprofWrapRouter(admin, "/pprof/", IPAllowlistGuard("192.0.2.0/24", "::1"), TokenGuard("secret"))
+	PprofWrapRouter(app.Group("default"), "")
+
+	for _, tc := range []struct {
+		giveMethod string
+		giveUrl    string
+		giveIP     string
+		giveToken  string
+		wantCode   int
+	}{
+		{"GET", "/admin/pprof/", "192.0.2.1:1234", "secret", 200},
+		{"GET", "/admin/pprof/heap", "192.0.2.1:1234", "secret", 200},
+		{"GET", "/admin/pprof/goroutine?debug=1", "[::1]:1234", "secret", 200},
+		{"GET", "/admin/pprof/xgin_custom", "192.0.2.1:1234", "secret", 200},
+		{"GET", "/admin/pprof/cmdline", "192.0.2.1:1234", "secret", 200},
+		{"POST", "/admin/pprof/symbol", "192.0.2.1:1234", "secret", 200},
+		{"GET", "/admin/pprof/heap", "198.51.100.1:1234", "secret", 403},
+		{"GET", "/admin/pprof/heap", "192.0.2.1:1234", "", 401},
+		{"GET", "/admin/pprof/heap", "192.0.2.1:1234", "wrong", 401},
+		{"GET", "/admin/pprof/heap?token=secret", "192.0.2.1:1234", "", 401},
+		{"GET", "/admin/pprof/unknown", "192.0.2.1:1234", "secret", 404},
+		{"GET", "/debug/pprof/heap", "192.0.2.1:1234", "", 404},
+		{"GET", "/default/debug/pprof/heap", "198.51.100.1:1234", "", 200},
+		{"GET", "/default/debug/pprof/xgin_custom", "198.51.100.1:1234", "", 200},
+	} {
+		req := httptest.NewRequest(tc.giveMethod, tc.giveUrl, nil)
+		req.RemoteAddr = tc.giveIP
+		if tc.giveToken != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.giveToken)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		xtesting.Equal(t, w.Code, tc.wantCode)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/default/debug/pprof/", nil)
+	app.ServeHTTP(w, req)
+	xtesting.True(t, strings.Contains(w.Body.String(), "xgin_custom"))
+
+	xtesting.Panic(t, func() { IPAllowlistGuard("127.0.0.256") })
+	xtesting.Panic(t, func() { IPAllowlistGuard("10.0.0.0/33") })
+	xtesting.Panic(t, func() { ProxiedIPAllowlistGuard([]string{"10.0.0.256"}) })
+
+	// spoofing
+	app = gin.New()
+	app.GET("/direct", IPAllowlistGuard("127.0.0.1", "192.0.2.0/24"))
+	app.GET("/proxied", ProxiedIPAllowlistGuard([]string{"10.0.0.0/8"}, "192.0.2.0/24"))
+	for _, tc := range []struct {
+		giveUrl    string
+		giveIP     string
+		giveHeader map[string]string
+		wantCode   int
+	}{
+		{"/direct", "192.0.2.1:1234", nil, 200},
+		{"/direct", "192.0.2.1", nil, 200},
+		{"/direct", "198.51.100.1:1234", map[string]string{"X-Forwarded-For": "127.0.0.1"}, 403},
+		{"/direct", "198.51.100.1:1234", map[string]string{"X-Real-IP": "192.0.2.1"}, 403},
+		{"/direct", "invalid", nil, 403},
+		{"/proxied", "198.51.100.1:1234", map[string]string{"X-Forwarded-For": "192.0.2.1"}, 403},
+		{"/proxied", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "192.0.2.1"}, 200},
+		{"/proxied", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "192.0.2.1, 10.0.0.2"}, 200},
+		{"/proxied", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "192.0.2.1, 198.51.100.1"}, 403},
+		{"/proxied", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "192.0.2.1, x"}, 403},
+		{"/proxied", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3"}, 403},
+		{"/proxied", "10.0.0.1:1234", map[string]string{"X-Real-IP": "192.0.2.1"}, 200},
+		{"/proxied", "10.0.0.1:1234", nil, 403},
+		{"/proxied", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, 200},
+	} {
+		req := httptest.NewRequest("GET", tc.giveUrl, nil)
+		req.RemoteAddr = tc.giveIP
+		for k, v := range tc.giveHeader {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		xtesting.Equal(t, w.Code, tc.wantCode)
+	}
+}
+
+func TestRuntimeControlWrap(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	RuntimeControlWrap(app, "", TokenGuard("secret"))
+	RuntimeControlWrap(app.Group("admin"), "runtime")
+	defer func() {
+		runtime.SetBlockProfileRate(0)
+		runtime.SetMutexProfileFraction(0)
+		debug.SetGCPercent(100)
+		blockProfileRate, gcPercent = 0, 100
+	}()
+
+	do := func(method, url string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		m := make(map[string]interface{})
+		_ = json.Unmarshal(w.Body.Bytes(), &m)
+		return w.Code, m
+	}
+
+	code, m := do("GET", "/debug/runtime/")
+	xtesting.Equal(t, code, 200)
+	xtesting.Equal(t, m["block_profile_rate"], 0.0)
+	xtesting.Equal(t, m["mutex_profile_fraction"], 0.0)
+	xtesting.Equal(t, m["gc_percent"], 100.0)
+
+	code, m = do("PUT", "/debug/runtime/?block_profile_rate=1&mutex_profile_fraction=5")
+	xtesting.Equal(t, code, 200)
+	xtesting.Equal(t, m["block_profile_rate"], 1.0)
+	xtesting.Equal(t, m["mutex_profile_fraction"], 5.0)
+	xtesting.Equal(t, m["gc_percent"], 100.0)
+	xtesting.Equal(t, runtime.SetMutexProfileFraction(-1), 5)
+
+	code, m = do("PUT", "/admin/runtime/?gc_percent=50")
+	xtesting.Equal(t, code, 200)
+	xtesting.Equal(t, m["block_profile_rate"], 1.0)
+	xtesting.Equal(t, m["gc_percent"], 50.0)
+
+	for _, url := range []string{"/debug/runtime/?block_profile_rate=x", "/debug/runtime/?mutex_profile_fraction=-1", "/debug/runtime/?gc_percent=1.5"} {
+		code, m = do("PUT", url)
+		xtesting.Equal(t, code, 400)
+		xtesting.True(t, strings.HasPrefix(m["message"].(string), "invalid "))
+	}
+	code, m = do("GET", "/debug/runtime/")
+	xtesting.Equal(t, m["gc_percent"], 50.0)
+
+	// concurrent reading and changing
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); do("GET", "/debug/runtime/") }()
+		go func(v int) { defer wg.Done(); do("PUT", "/debug/runtime/?gc_percent="+strconv.Itoa(v)) }(60 + i%2)
+	}
+	wg.Wait()
+	code, m = do("PUT", "/debug/runtime/?gc_percent=70")
+	xtesting.Equal(t, m["gc_percent"], 70.0)
+	xtesting.Equal(t, debug.SetGCPercent(70), 70)
+
+	code, m = do("POST", "/debug/runtime/gc")
+	xtesting.Equal(t, code, 200)
+	xtesting.True(t, m["num_gc"].(float64) >= 1)
+	xtesting.NotNil(t, m["heap_alloc_before"])
+	xtesting.NotNil(t, m["heap_alloc_after"])
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/debug/runtime/", nil))
+	xtesting.Equal(t, w.Code, 401)
+}
+
+func TestProfileCollector(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	dir, err := ioutil.TempDir("", "xgin_profiles")
+	xtesting.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// collect and rotate by count
+	errs := make([]error, 0)
+	collector := NewProfileCollector(dir, WithCPUProfileDuration(20*time.Millisecond), WithMaxProfileCount(6),
+		WithCollectErrorHandler(func(err error) { errs = append(errs, err) }))
+	xtesting.Nil(t, collector.Collect())
+	files, err := collector.files()
+	xtesting.Nil(t, err)
+	xtesting.Equal(t, len(files), 4)
+	for idx, name := range []string{"cpu", "goroutine", "heap", "mutex"} {
+		xtesting.Equal(t, files[idx].Profile, name)
+		xtesting.True(t, strings.HasPrefix(files[idx].Name, name+"-"))
+	}
+	time.Sleep(5 * time.Millisecond)
+	xtesting.Nil(t, collector.Collect())
+	files, _ = collector.files()
+	xtesting.Equal(t, len(files), 6)
+	xtesting.Equal(t, files[0].Profile, "heap") // cpu and goroutine in the first round are removed
+	xtesting.Equal(t, files[1].Profile, "mutex")
+	xtesting.Equal(t, len(errs), 0)
+
+	// rotate by size
+	xtesting.Nil(t, ioutil.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("ignored"), 0644))
+	xtesting.Nil(t, ioutil.WriteFile(filepath.Join(dir, "ignored-x.pb.gz"), []byte("ignored"), 0644))
+	collector = NewProfileCollector(dir, WithCollectProfiles("goroutine"), WithMaxProfileCount(0), WithMaxProfileSize(1))
+	xtesting.Nil(t, collector.Collect())
+	files, _ = collector.files()
+	xtesting.Equal(t, len(files), 0)
+	_, err = os.Stat(filepath.Join(dir, "ignored.txt"))
+	xtesting.Nil(t, err)
+
+	// unknown profile
+	collector = NewProfileCollector(dir, WithCollectProfiles("unknown", "heap"), WithCollectErrorHandler(func(err error) { errs = append(errs, err) }))
+	xtesting.NotNil(t, collector.Collect())
+	xtesting.Equal(t, len(errs), 1)
+	files, _ = collector.files()
+	xtesting.Equal(t, len(files), 1)
+
+	// cpu profiling in use
+	xtesting.Nil(t, runtimepprof.StartCPUProfile(ioutil.Discard))
+	collector = NewProfileCollector(dir, WithCollectProfiles("cpu"))
+	err = collector.Collect()
+	runtimepprof.StopCPUProfile()
+	xtesting.NotNil(t, err)
+	cpuFiles, _ := filepath.Glob(filepath.Join(dir, "cpu-*"+profileFileSuffix))
+	xtesting.Equal(t, len(cpuFiles), 0)
+
+	// start and stop
+	collector = NewProfileCollector(dir, WithCollectInterval(0))
+	xtesting.NotNil(t, collector.Start())
+	collector = NewProfileCollector(dir, WithCollectInterval(10*time.Millisecond), WithCPUProfileDuration(time.Hour), WithCollectProfiles("heap", "cpu"))
+	collector.Stop() // not started
+	xtesting.Nil(t, collector.Start())
+	xtesting.NotNil(t, collector.Start())
+	time.Sleep(20 * time.Millisecond)
+	collector.Stop() // interrupt cpu profiling
+	files, _ = collector.files()
+	xtesting.True(t, len(files) >= 2)
+
+	// list and download
+	app := gin.New()
+	collector.Wrap(app, "")
+	NewProfileCollector(filepath.Join(dir, "not_exist")).Wrap(app, "/empty")
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/debug/profiles/", nil))
+	xtesting.Equal(t, w.Code, 200)
+	list := make([]map[string]interface{}, 0)
+	xtesting.Nil(t, json.Unmarshal(w.Body.Bytes(), &list))
+	xtesting.Equal(t, len(list), len(files))
+	xtesting.Equal(t, list[0]["name"], files[0].Name)
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/debug/profiles/"+files[0].Name, nil))
+	xtesting.Equal(t, w.Code, 200)
+	xtesting.Equal(t, w.Header().Get("Content-Disposition"), `attachment; filename="`+files[0].Name+`"`)
+	for _, name := range []string{"ignored.txt", "..%2Fxxx.pb.gz", "not-exist.pb.gz"} {
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest("GET", "/debug/profiles/"+name, nil))
+		xtesting.Equal(t, w.Code, 404)
+	}
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/empty/", nil))
+	xtesting.Equal(t, w.Body.String(), "[]")
+}
+
+func TestGoroutineDiffWrap(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	profile := `goroutine profile: total 4
+2 @ 0x43a8c5 0x4071ba 0x6c5f4b 0x46f8e1
+#	0x6c5f4a	main.worker+0x2a	/path/to/main.go:12
+#	0x46f8e0	runtime.goexit+0x1	/usr/local/go/src/runtime/asm_amd64.s:1357
+
+1 @ 0x43a8c5 0x44a0ef
+#	0x44a0ee	main.main+0x2e		/path/to/main.go:20
+
+1 @ 0x43a8c5 0x4071ba 0x6c5f4b 0x46f8e1
+#	0x6c5f4a	main.worker+0x3b	/path/to/main.go:12
+#	0x46f8e0	runtime.goexit+0x1	/usr/local/go/src/runtime/asm_amd64.s:1357
+`
+	xtesting.Equal(t, parseGoroutineProfile(profile), map[string]int{
+		"main.worker /path/to/main.go:12\nruntime.goexit /usr/local/go/src/runtime/asm_amd64.s:1357": 3,
+		"main.main /path/to/main.go:20": 1,
+	})
+	xtesting.Equal(t, diffGoroutineSnapshots(
+		&goroutineSnapshot{counts: map[string]int{"a": 1, "b": 2, "c": 3}},
+		&goroutineSnapshot{counts: map[string]int{"a": 1, "b": 5, "d": 1}},
+	), []*goroutineDiff{{"b", 2, 5, 3}, {"d", 0, 1, 1}, {"c", 3, 0, -3}})
+
+	app := gin.New()
+	GoroutineDiffWrap(app, "", TokenGuard("secret"))
+	do := func(method, url string) (int, interface{}) {
+		req := httptest.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		var v interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &v)
+		return w.Code, v
+	}
+
+	code, v := do("POST", "/debug/goroutines/snapshots/before")
+	xtesting.Equal(t, code, 200)
+	xtesting.Equal(t, v.(map[string]interface{})["name"], "before")
+	stopCh := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		go func() { <-stopCh }() // leaked goroutines
+	}
+	defer close(stopCh)
+	code, _ = do("POST", "/debug/goroutines/snapshots/after")
+	xtesting.Equal(t, code, 200)
+	code, v = do("GET", "/debug/goroutines/snapshots")
+	xtesting.Equal(t, code, 200)
+	xtesting.Equal(t, len(v.([]interface{})), 2)
+	xtesting.Equal(t, v.([]interface{})[0].(map[string]interface{})["name"], "after")
+
+	for _, url := range []string{"/debug/goroutines/diff?from=before&to=after", "/debug/goroutines/diff?from=before"} {
+		code, v = do("GET", url)
+		xtesting.Equal(t, code, 200)
+		diffs := v.(map[string]interface{})["diffs"].([]interface{})
+		found := false
+		for _, d := range diffs {
+			d := d.(map[string]interface{})
+			if strings.Contains(d["signature"].(string), "xgin.TestGoroutineDiffWrap.func") && d["delta"].(float64) == 3 {
+				found = true
+			}
+		}
+		xtesting.True(t, found)
+	}
+
+	code, _ = do("GET", "/debug/goroutines/diff?from=xxx")
+	xtesting.Equal(t, code, 404)
+	code, _ = do("GET", "/debug/goroutines/diff?from=before&to=xxx")
+	xtesting.Equal(t, code, 404)
+	code, _ = do("DELETE", "/debug/goroutines/snapshots/after")
+	xtesting.Equal(t, code, 200)
+	code, _ = do("DELETE", "/debug/goroutines/snapshots/after")
+	xtesting.Equal(t, code, 404)
+	code, _ = do("GET", "/debug/goroutines/diff?from=before&to=after")
+	xtesting.Equal(t, code, 404)
+}
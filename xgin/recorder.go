@@ -0,0 +1,215 @@
+package xgin
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RecordedExchange represents a recorded request and response pair, which is stored in golden file by RecordExchanges.
+type RecordedExchange struct {
+	Request  *RecordedRequest  `json:"request"`
+	Response *RecordedResponse `json:"response"`
+}
+
+// RecordedRequest represents a recorded request, used in RecordedExchange.
+type RecordedRequest struct {
+	Method       string   `json:"method"`        // request method
+	URL          string   `json:"url"`           // request uri, such as "/a/b?c=d"
+	Headers      []string `json:"headers"`       // request headers, in "XXX: YYY" format and sorted by name
+	Body         string   `json:"body"`          // request body, encoded by BodyEncoding, use DecodeBody to get the raw body
+	BodyEncoding string   `json:"body_encoding"` // encoding of body, such as "base64", empty means plain text
+}
+
+// RecordedResponse represents a recorded response, used in RecordedExchange.
+type RecordedResponse struct {
+	Status       int      `json:"status"`        // response status code
+	Headers      []string `json:"headers"`       // response headers, in "XXX: YYY" format and sorted by name
+	Body         string   `json:"body"`          // response body, encoded by BodyEncoding, use DecodeBody to get the raw body
+	BodyEncoding string   `json:"body_encoding"` // encoding of body, such as "base64", empty means plain text
+}
+
+// DecodeBody decodes and returns the raw request body of RecordedRequest.
+func (r *RecordedRequest) DecodeBody() ([]byte, error) {
+	return decodeRecordedBody(r.Body, r.BodyEncoding)
+}
+
+// DecodeBody decodes and returns the raw response body of RecordedResponse.
+func (r *RecordedResponse) DecodeBody() ([]byte, error) {
+	return decodeRecordedBody(r.Body, r.BodyEncoding)
+}
+
+// _base64Encoding is the body encoding used by RecordExchanges.
+const _base64Encoding = "base64"
+
+// decodeRecordedBody decodes given recorded body using given encoding.
+func decodeRecordedBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case _base64Encoding:
+		return base64.StdEncoding.DecodeString(body)
+	default:
+		return nil, fmt.Errorf("xgin: unknown body encoding '%s'", encoding)
+	}
+}
+
+// maxRecordBodySize represents the max body size for RecordExchanges, which is used to capture the whole body.
+const maxRecordBodySize = math.MaxInt32
+
+// RecordExchanges creates a gin.HandlerFunc which records the request and response pairs to golden files in given directory, using given
+// DumpRequestOption-s. Each exchange will be stored in a json file named by request method, path and a hash of query and body, and the same
+// request will overwrite the existing file. The bodies are always recorded as a whole in base64 encoding, which means WithBodyLimit is ignored.
+// Note that the header options and WithSecretFields are only applied to the recorded request, and the response is recorded as it is, so that
+// ReplayExchanges can serve it faithfully. Errors in recording will be added to gin.Context's errors.
+//
+// Example:
+// 	app.Use(xgin.RecordExchanges("./testdata/golden", xgin.WithSecretHeaders("Authorization")))
+// 	// ./testdata/golden/GET_v1_users_xxxxxxxxxxxx.json
+func RecordExchanges(dir string, options ...DumpRequestOption) gin.HandlerFunc {
+	opt := newDumpRequestOptions(options)
+	dumper := DumpResponse(nil, WithBodyLimit(maxRecordBodySize)) // no masking for response
+
+	return func(c *gin.Context) {
+		body := readAndRestoreBody(c.Request)
+		filename := exchangeFilename(c.Request.Method, c.Request.URL, body)
+		requestBody := string(body)
+		if len(opt.secretFields) != 0 {
+			requestBody = maskBodyFields(requestBody, c.Request.Header.Get("Content-Type"), opt)
+		}
+		request := &RecordedRequest{
+			Method:       c.Request.Method,
+			URL:          c.Request.URL.RequestURI(),
+			Headers:      dumpHeaders(c.Request.Header, opt),
+			Body:         base64.StdEncoding.EncodeToString([]byte(requestBody)),
+			BodyEncoding: _base64Encoding,
+		}
+
+		dumper(c) // c.Next() inside
+		dumped, _ := GetDumpedResponse(c)
+		response := &RecordedResponse{
+			Status:       dumped.Status,
+			Headers:      dumped.Headers,
+			Body:         base64.StdEncoding.EncodeToString([]byte(dumped.Body)),
+			BodyEncoding: _base64Encoding,
+		}
+		bs, _ := json.MarshalIndent(&RecordedExchange{Request: request, Response: response}, "", "  ") // ignore error
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, filename), bs, 0644); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// ReplayExchanges creates a gin.HandlerFunc which serves the recorded responses from golden files in given directory, these files are
+// recorded by RecordExchanges. The request will be matched by method, path, query and body, and the remaining handlers will be aborted
+// when a recorded exchange is found, otherwise the remaining handlers will be invoked. This can be used as a local stand-in for upstream
+// services in tests.
+//
+// Example:
+// 	upstream := gin.New()
+// 	upstream.Use(xgin.ReplayExchanges("./testdata/golden"))
+// 	server := httptest.NewServer(upstream) // 404 for not recorded requests
+func ReplayExchanges(dir string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := readAndRestoreBody(c.Request)
+		filename := exchangeFilename(c.Request.Method, c.Request.URL, body)
+		bs, err := ioutil.ReadFile(filepath.Join(dir, filename))
+		if err != nil {
+			c.Next()
+			return
+		}
+		exchange := &RecordedExchange{}
+		if err = json.Unmarshal(bs, exchange); err != nil || exchange.Response == nil {
+			_ = c.Error(fmt.Errorf("xgin: invalid golden file '%s'", filename))
+			c.Next()
+			return
+		}
+		responseBody, err := exchange.Response.DecodeBody()
+		if err != nil {
+			_ = c.Error(fmt.Errorf("xgin: invalid golden file '%s': %v", filename, err))
+			c.Next()
+			return
+		}
+
+		for _, kv := range splitDumpedHeaders(exchange.Response.Headers) {
+			c.Writer.Header().Add(kv.Name, kv.Value)
+		}
+		c.Status(exchange.Response.Status)
+		_, _ = c.Writer.Write(responseBody)
+		c.Abort()
+	}
+}
+
+// LoadExchanges loads all the RecordedExchange-s from golden files in given directory, which are sorted by filename. This can be used to
+// check handlers against the recorded traffic.
+func LoadExchanges(dir string) ([]*RecordedExchange, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filenames)
+
+	out := make([]*RecordedExchange, 0, len(filenames))
+	for _, filename := range filenames {
+		bs, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		exchange := &RecordedExchange{}
+		if err = json.Unmarshal(bs, exchange); err != nil {
+			return nil, err
+		}
+		out = append(out, exchange)
+	}
+	return out, nil
+}
+
+// readAndRestoreBody reads the whole body from http.Request, and puts the read bytes back.
+func readAndRestoreBody(req *http.Request) []byte {
+	if req.Body == nil || req.Body == http.NoBody {
+		return []byte{}
+	}
+	bs, _ := ioutil.ReadAll(req.Body) // ignore error
+	req.Body = &readCloser{Reader: bytes.NewReader(bs), Closer: req.Body}
+	return bs
+}
+
+// exchangeFilename generates a golden filename using given method, url and body, such as "GET_v1_users_0123456789ab.json".
+func exchangeFilename(method string, u *url.URL, body []byte) string {
+	h := sha1.New()
+	_, _ = h.Write([]byte(u.Path))
+	_, _ = h.Write([]byte{'\n'})
+	_, _ = h.Write([]byte(u.Query().Encode())) // sorted by key
+	_, _ = h.Write([]byte{'\n'})
+	_, _ = h.Write(body)
+	sum := h.Sum(nil)
+
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, strings.Trim(u.Path, "/"))
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	if name == "" {
+		name = "_"
+	}
+	return fmt.Sprintf("%s_%s_%x.json", method, name, sum[:6])
+}
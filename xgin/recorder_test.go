@@ -0,0 +1,177 @@
+package xgin
+
+import (
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplayExchanges(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	dir, err := ioutil.TempDir("", "xgin_golden")
+	xtesting.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// record
+	upstream := gin.New()
+	upstream.Use(RecordExchanges(dir, WithIgnoreHeaders("User-Agent"), WithSecretHeaders("Authorization"), WithSecretFields("password")))
+	upstream.GET("/users/:id", func(c *gin.Context) {
+		c.Header("X-Id", c.Param("id"))
+		c.JSON(200, gin.H{"id": c.Param("id"), "q": c.Query("q")})
+	})
+	upstream.POST("/login", func(c *gin.Context) {
+		bs, _ := ioutil.ReadAll(c.Request.Body) // body can still be read
+		c.Header("Set-Cookie", "session=xxx")
+		c.String(201, "login: %s", string(bs))
+	})
+	upstream.POST("/binary", func(c *gin.Context) {
+		bs, _ := ioutil.ReadAll(c.Request.Body)
+		c.JSON(200, gin.H{"password": "p", "size": len(bs)})
+	})
+	upstream.GET("/binary", func(c *gin.Context) {
+		c.Data(200, "application/octet-stream", []byte{0xff, 0x00, 0xfe, '"'})
+	})
+
+	for _, tc := range []struct {
+		giveMethod string
+		giveUrl    string
+		giveBody   string
+		wantResp   string
+	}{
+		{"GET", "/users/1?q=a&r=b", "", `{"id":"1","q":"a"}`},
+		{"GET", "/users/2", "", `{"id":"2","q":""}`},
+		{"POST", "/login", `{"username":"u","password":"p"}`, `login: {"username":"u","password":"p"}`},
+		{"POST", "/binary", "\xff\x00", `{"password":"p","size":2}`},
+		{"GET", "/binary", "", "\xff\x00\xfe\""},
+	} {
+		req := httptest.NewRequest(tc.giveMethod, tc.giveUrl, strings.NewReader(tc.giveBody))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("User-Agent", "test")
+		if strings.HasPrefix(tc.giveBody, "{") {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		upstream.ServeHTTP(w, req)
+		xtesting.Equal(t, w.Body.String(), tc.wantResp)
+	}
+
+	// load
+	exchanges, err := LoadExchanges(dir)
+	xtesting.Nil(t, err)
+	xtesting.Equal(t, len(exchanges), 5)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	for idx, file := range files {
+		files[idx] = filepath.Base(file)
+	}
+	xtesting.True(t, strings.HasPrefix(files[0], "GET_binary_"))
+	xtesting.True(t, strings.HasPrefix(files[1], "GET_users_1_"))
+	xtesting.True(t, strings.HasPrefix(files[2], "GET_users_2_"))
+	xtesting.True(t, strings.HasPrefix(files[3], "POST_binary_"))
+	xtesting.True(t, strings.HasPrefix(files[4], "POST_login_"))
+	xtesting.Equal(t, exchanges[1].Request, &RecordedRequest{Method: "GET", URL: "/users/1?q=a&r=b", Headers: []string{"Authorization: *"}, Body: "", BodyEncoding: "base64"})
+	xtesting.Equal(t, exchanges[1].Response, &RecordedResponse{Status: 200, Headers: []string{"Content-Type: application/json; charset=utf-8", "X-Id: 1"},
+		Body: "eyJpZCI6IjEiLCJxIjoiYSJ9", BodyEncoding: "base64"}) // {"id":"1","q":"a"}
+	for _, tc := range []struct {
+		give     *RecordedExchange
+		wantReq  string
+		wantResp string
+	}{
+		{exchanges[0], "", "\xff\x00\xfe\""},
+		{exchanges[3], "\xff\x00", `{"password":"p","size":2}`}, // response is not masked
+		{exchanges[4], `{"username":"u","password":"*"}`, `login: {"username":"u","password":"p"}`},
+	} {
+		bs, err := tc.give.Request.DecodeBody()
+		xtesting.Nil(t, err)
+		xtesting.Equal(t, string(bs), tc.wantReq)
+		bs, err = tc.give.Response.DecodeBody()
+		xtesting.Nil(t, err)
+		xtesting.Equal(t, string(bs), tc.wantResp)
+	}
+	xtesting.Equal(t, exchanges[4].Response.Headers, []string{"Content-Type: text/plain; charset=utf-8", "Set-Cookie: session=xxx"})
+	bs, err := (&RecordedResponse{Body: "plain"}).DecodeBody()
+	xtesting.Nil(t, err)
+	xtesting.Equal(t, string(bs), "plain")
+	_, err = (&RecordedResponse{Body: "plain", BodyEncoding: "gzip"}).DecodeBody()
+	xtesting.NotNil(t, err)
+	_, err = (&RecordedRequest{Body: "!", BodyEncoding: "base64"}).DecodeBody()
+	xtesting.NotNil(t, err)
+
+	// replay
+	replay := gin.New()
+	replay.Use(ReplayExchanges(dir))
+	replay.GET("/fallback", func(c *gin.Context) { c.String(200, "fallback") })
+	for _, tc := range []struct {
+		giveMethod string
+		giveUrl    string
+		giveBody   string
+		wantCode   int
+		wantResp   string
+		wantHeader string
+	}{
+		{"GET", "/users/1?r=b&q=a", "", 200, `{"id":"1","q":"a"}`, "1"},
+		{"GET", "/users/2", "", 200, `{"id":"2","q":""}`, "2"},
+		{"GET", "/users/2?q=a", "", 404, "404 page not found", ""},
+		{"GET", "/users/3", "", 404, "404 page not found", ""},
+		{"POST", "/login", `{"username":"u","password":"p"}`, 201, `login: {"username":"u","password":"p"}`, ""},
+		{"POST", "/binary", "\xff\x00", 200, `{"password":"p","size":2}`, ""},
+		{"GET", "/binary", "", 200, "\xff\x00\xfe\"", ""},
+		{"POST", "/login", `{"username":"u","password":"q"}`, 404, "404 page not found", ""},
+		{"GET", "/fallback", "", 200, "fallback", ""},
+	} {
+		w := httptest.NewRecorder()
+		replay.ServeHTTP(w, httptest.NewRequest(tc.giveMethod, tc.giveUrl, strings.NewReader(tc.giveBody)))
+		xtesting.Equal(t, w.Code, tc.wantCode)
+		xtesting.Equal(t, w.Body.String(), tc.wantResp)
+		xtesting.Equal(t, w.Header().Get("X-Id"), tc.wantHeader)
+	}
+	w := httptest.NewRecorder()
+	replay.ServeHTTP(w, httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"u","password":"p"}`)))
+	xtesting.Equal(t, w.Header().Get("Set-Cookie"), "session=xxx")
+
+	// invalid body encoding
+	u, _ := url.Parse("/encoding")
+	xtesting.Nil(t, ioutil.WriteFile(filepath.Join(dir, exchangeFilename("GET", u, []byte{})), []byte(`{"response": {"body": "x", "body_encoding": "x"}}`), 0644))
+	w = httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/encoding", nil)
+	ReplayExchanges(dir)(ctx)
+	xtesting.Equal(t, len(ctx.Errors), 1)
+
+	// invalid golden file and directory
+	u, _ = url.Parse("/invalid")
+	xtesting.Nil(t, ioutil.WriteFile(filepath.Join(dir, exchangeFilename("GET", u, []byte{})), []byte("{"), 0644))
+	w = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/invalid", nil)
+	ReplayExchanges(dir)(ctx)
+	xtesting.Equal(t, len(ctx.Errors), 1)
+	_, err = LoadExchanges(dir)
+	xtesting.NotNil(t, err)
+
+	file := filepath.Join(dir, "file")
+	xtesting.Nil(t, ioutil.WriteFile(file, []byte{}, 0644))
+	w = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest("GET", "/", nil)
+	RecordExchanges(file)(ctx)
+	xtesting.Equal(t, len(ctx.Errors), 1)
+
+	// filename
+	for _, tc := range []struct {
+		giveUrl string
+		want    string
+	}{
+		{"/", "GET___"},
+		{"/a/b.c/d-e", "GET_a_b.c_d-e_"},
+		{"/" + strings.Repeat("a", 70), "GET_" + strings.Repeat("a", 64) + "_"},
+	} {
+		u, _ := url.Parse(tc.giveUrl)
+		xtesting.True(t, strings.HasPrefix(exchangeFilename("GET", u, nil), tc.want))
+	}
+}
@@ -0,0 +1,207 @@
+package xgin
+
+import (
+	"github.com/Aoi-hosizora/ahlib-more/xvalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/universal-translator"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TranslatorRegistry represents a registry of validator translators for several locales, which can be used to choose translator per request,
+// see TranslatorRegistry.Middleware. All the translators registered are set up with the translations of custom bindings, such as `regexp`,
+// `date` and `datetime`.
+type TranslatorRegistry struct {
+	mu          sync.RWMutex
+	translators map[string]ut.Translator // normalized locale -> translator
+	locales     []string                 // locale names in registration order, the first one is the default
+}
+
+// NewTranslatorRegistry creates an empty TranslatorRegistry, the first registered translator will be used as the default one.
+//
+// Example:
+// 	registry := xgin.NewTranslatorRegistry()
+// 	_, _ = registry.Register(xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc()) // default
+// 	_, _ = registry.Register(xvalidator.ZhLocaleTranslator(), xvalidator.ZhTranslationRegisterFunc())
+// 	app.Use(registry.Middleware("lang"))
+// 	app.POST("/users", func(c *gin.Context) {
+// 		translator, _ := xgin.GetTranslator(c)
+// 		...
+// 	})
+func NewTranslatorRegistry() *TranslatorRegistry {
+	return &TranslatorRegistry{translators: make(map[string]ut.Translator)}
+}
+
+// Register creates a ut.Translator for gin's validator engine using given locales.Translator and xvalidator.TranslationRegisterHandler, sets
+// up the translations of all the custom bindings in this package (such as `regexp`, `date` and `datetime`), and adds it to the registry. Note
+// that the bindings themselves should be enabled by EnableParamRegexpBinding and so on. Also see GetValidatorTranslator.
+func (r *TranslatorRegistry) Register(locTranslator locales.Translator, registerFn xvalidator.TranslationRegisterHandler) (ut.Translator, error) {
+	translator, err := GetValidatorTranslator(locTranslator, registerFn)
+	if err != nil {
+		return nil, err
+	}
+	if err = addAllBindingTranslators(translator, AddTranslator); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := normalizeLocale(translator.Locale())
+	if _, ok := r.translators[key]; !ok {
+		r.locales = append(r.locales, translator.Locale())
+	}
+	r.translators[key] = translator
+	return translator, nil
+}
+
+// Locales returns the registered locale names in registration order, such as "en" and "zh_Hant".
+func (r *TranslatorRegistry) Locales() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(make([]string, 0, len(r.locales)), r.locales...)
+}
+
+// Default returns the default translator, which is the first registered one, returns nil if the registry is empty.
+func (r *TranslatorRegistry) Default() ut.Translator {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.locales) == 0 {
+		return nil
+	}
+	return r.translators[normalizeLocale(r.locales[0])]
+}
+
+// localeAliases stores some language tags which can not be matched by base language.
+var localeAliases = map[string]string{
+	"zh-tw":   "zh-hant",
+	"zh-hk":   "zh-hant",
+	"zh-mo":   "zh-hant",
+	"zh-hans": "zh",
+	"zh-cn":   "zh",
+	"zh-sg":   "zh",
+}
+
+// normalizeLocale normalizes given locale or language tag, such as "zh_Hant" to "zh-hant".
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// Get returns the translator for given locale or language tag, such as "zh", "zh_Hant", "zh-TW" and "pt-BR". The locale is matched in
+// case-insensitive, and then by aliases (such as "zh-TW" to "zh_Hant"), shorter prefixes (such as "en-US" to "en") and other locales with
+// the same base language (such as "pt" to "pt_BR").
+func (r *TranslatorRegistry) Get(locale string) (ut.Translator, bool) {
+	tag := normalizeLocale(locale)
+	if tag == "" {
+		return nil, false
+	}
+	candidates := make([]string, 0, 4)
+	for prefix := tag; ; { // "zh-hant-tw" -> "zh-hant" -> "zh"
+		candidates = append(candidates, prefix)
+		if alias, ok := localeAliases[prefix]; ok {
+			candidates = append(candidates, alias)
+		}
+		idx := strings.LastIndex(prefix, "-")
+		if idx == -1 {
+			break
+		}
+		prefix = prefix[:idx]
+	}
+	base := strings.SplitN(tag, "-", 2)[0]
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, candidate := range candidates {
+		if translator, ok := r.translators[candidate]; ok {
+			return translator, true
+		}
+	}
+	for _, l := range r.locales {
+		if key := normalizeLocale(l); strings.HasPrefix(key, base+"-") {
+			return r.translators[key], true
+		}
+	}
+	return nil, false
+}
+
+// Match returns the best translator for given Accept-Language header value, such as "zh-CN,zh;q=0.9,en;q=0.8", returns the default
+// translator if no locale is matched.
+func (r *TranslatorRegistry) Match(acceptLanguage string) ut.Translator {
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if translator, ok := r.Get(tag); ok {
+			return translator
+		}
+	}
+	return r.Default()
+}
+
+// parseAcceptLanguage parses given Accept-Language header value, and returns the language tags sorted by quality, tags with zero quality
+// and "*" will be ignored.
+func parseAcceptLanguage(acceptLanguage string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+	languages := make([]language, 0)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		sp := strings.Split(part, ";")
+		tag := strings.TrimSpace(sp[0])
+		quality := 1.0
+		for _, param := range sp[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if tag != "" && tag != "*" && quality > 0 {
+			languages = append(languages, language{tag: tag, quality: quality})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+
+	out := make([]string, 0, len(languages))
+	for _, l := range languages {
+		out = append(out, l.tag)
+	}
+	return out
+}
+
+// translatorKey is the gin.Context key for the translator chosen by TranslatorRegistry.Middleware.
+const translatorKey = "xgin.translator"
+
+const (
+	panicEmptyTranslatorRegistry = "xgin: no translator is registered"
+)
+
+// Middleware creates a gin.HandlerFunc which chooses a translator for each request and stores it in gin.Context, the translator is chosen
+// by given query key (such as "?lang=zh", ignored if the key is empty) first, then by Accept-Language header, and the default translator is
+// used if no locale is matched. Use GetTranslator to get the chosen translator, and note that this function panics if the registry is empty.
+func (r *TranslatorRegistry) Middleware(queryKey string) gin.HandlerFunc {
+	if r.Default() == nil {
+		panic(panicEmptyTranslatorRegistry)
+	}
+	return func(c *gin.Context) {
+		var translator ut.Translator
+		if queryKey != "" {
+			translator, _ = r.Get(c.Query(queryKey))
+		}
+		if translator == nil {
+			translator = r.Match(c.GetHeader("Accept-Language"))
+		}
+		c.Set(translatorKey, translator)
+		c.Next()
+	}
+}
+
+// GetTranslator returns the translator stored in gin.Context by TranslatorRegistry.Middleware.
+func GetTranslator(c *gin.Context) (ut.Translator, bool) {
+	v, ok := c.Get(translatorKey)
+	if !ok {
+		return nil, false
+	}
+	translator, ok := v.(ut.Translator)
+	return translator, ok
+}
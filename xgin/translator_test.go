@@ -0,0 +1,125 @@
+package xgin
+
+import (
+	"encoding/json"
+	"github.com/Aoi-hosizora/ahlib-more/xvalidator"
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTranslatorRegistry(t *testing.T) {
+	registry := NewTranslatorRegistry()
+	xtesting.Nil(t, registry.Default())
+	xtesting.Panic(t, func() { registry.Middleware("lang") })
+	for _, tc := range []struct {
+		giveTranslator locales.Translator
+		giveRegisterFn xvalidator.TranslationRegisterHandler
+	}{
+		{xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc()},
+		{xvalidator.ZhLocaleTranslator(), xvalidator.ZhTranslationRegisterFunc()},
+		{xvalidator.ZhHantLocaleTranslator(), xvalidator.ZhTwTranslationRegisterFunc()},
+		{xvalidator.PtBrLocaleTranslator(), xvalidator.PtBrTranslationRegisterFunc()},
+		{xvalidator.JaLocaleTranslator(), xvalidator.JaTranslationRegisterFunc()},
+		{xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc()}, // override
+	} {
+		_, err := registry.Register(tc.giveTranslator, tc.giveRegisterFn)
+		xtesting.Nil(t, err)
+	}
+	xtesting.Equal(t, registry.Locales(), []string{"en", "zh", "zh_Hant", "pt_BR", "ja"})
+	xtesting.Equal(t, registry.Default().Locale(), "en")
+
+	// get
+	for _, tc := range []struct {
+		give   string
+		want   string
+		wantOk bool
+	}{
+		{"", "", false},
+		{"fr", "", false},
+		{"en", "en", true},
+		{"EN-us", "en", true},
+		{"zh", "zh", true},
+		{"zh-CN", "zh", true},
+		{"zh-Hans-CN", "zh", true},
+		{"zh_Hant", "zh_Hant", true},
+		{"zh-TW", "zh_Hant", true},
+		{"zh-Hant-HK", "zh_Hant", true},
+		{"pt", "pt_BR", true},
+		{"pt-PT", "pt_BR", true},
+		{"ja-JP", "ja", true},
+	} {
+		translator, ok := registry.Get(tc.give)
+		xtesting.Equal(t, ok, tc.wantOk)
+		if ok {
+			xtesting.Equal(t, translator.Locale(), tc.want)
+		}
+	}
+
+	// match
+	for _, tc := range []struct {
+		give string
+		want string
+	}{
+		{"", "en"},
+		{"*", "en"},
+		{"fr-FR,fr;q=0.9", "en"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh"},
+		{"fr;q=0.9, ja;q=0.95, zh-TW;q=0.5", "ja"},
+		{"zh;q=0, en-GB;q=0.8", "en"},
+		{"en;q=0.1, zh-TW", "zh_Hant"},
+		{"en;q=abc, zh", "en"},
+	} {
+		xtesting.Equal(t, registry.Match(tc.give).Locale(), tc.want)
+	}
+	xtesting.Equal(t, parseAcceptLanguage(" da, en-gb;q=0.8, en;q=0.7, *;q=0.1"), []string{"da", "en-gb", "en"})
+
+	// middleware
+	xtesting.Nil(t, EnableParamRegexpBinding())
+	type testStruct struct {
+		Name string `form:"name" binding:"required"`
+		Code string `form:"code" binding:"regexp=^[0-9]+$"`
+	}
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(registry.Middleware("lang"))
+	app.GET("/", func(c *gin.Context) {
+		obj := &testStruct{}
+		translator, ok := GetTranslator(c)
+		xtesting.True(t, ok)
+		be := ParseBindError(c.ShouldBindWith(obj, binding.Query), obj, translator)
+		c.JSON(400, be)
+	})
+	for _, tc := range []struct {
+		giveQuery  string
+		giveHeader string
+		wantName   string
+		wantCode   string
+	}{
+		{"", "", "Name is a required field", "Code must matches regexp /^[0-9]+$/"},
+		{"lang=zh", "en", "Name为必填字段", "Code必须匹配正则表达式/^[0-9]+$/"},
+		{"lang=fr", "zh-TW", "Name為必填欄位", "Code必須匹配正規表示式/^[0-9]+$/"},
+		{"", "ja,en;q=0.5", "Nameは必須フィールドです", "Codeは正規表現/^[0-9]+$/に一致する必要があります"},
+		{"", "pt-BR", "Name é um campo requerido", "Code deve corresponder à expressão regular /^[0-9]+$/"},
+	} {
+		req := httptest.NewRequest("GET", "/?code=x&"+tc.giveQuery, nil)
+		req.Header.Set("Accept-Language", tc.giveHeader)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		be := &BindError{}
+		xtesting.Nil(t, json.Unmarshal(w.Body.Bytes(), be))
+		xtesting.Equal(t, len(be.Fields), 2)
+		xtesting.Equal(t, be.Fields[0].Message, tc.wantName)
+		xtesting.Equal(t, be.Fields[1].Message, tc.wantCode)
+	}
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, ok := GetTranslator(ctx)
+	xtesting.False(t, ok)
+	ctx.Set(translatorKey, "")
+	_, ok = GetTranslator(ctx)
+	xtesting.False(t, ok)
+}
@@ -0,0 +1,368 @@
+package xgin
+
+import (
+	"github.com/Aoi-hosizora/ahlib-more/xvalidator"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales"
+	"github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Validator represents a validator engine holder which can be attached to gin.Engine or gin.RouterGroup by Validator.Middleware, so that
+// different engines or route groups can use different validation rules, instead of the process-global binding.Validator. Use the bind
+// helpers such as ShouldBind and ShouldBindJSON to bind with the attached Validator, which fall back to binding.Validator if there is no
+// Validator attached. Validator also implements binding.StructValidator, so it can also be used as the global one.
+//
+// Example:
+// 	admin := xgin.NewValidator()
+// 	_ = admin.AddBinding("regexp", xvalidator.ParamRegexpValidator())
+// 	translator, _ := admin.Translator(xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc())
+// 	adminGroup := app.Group("admin", admin.Middleware())
+// 	adminGroup.POST("users", func(c *gin.Context) {
+// 		req := &CreateUserRequest{}
+// 		if err := xgin.ShouldBindJSON(c, req); err != nil {
+// 			c.JSON(400, xgin.ParseBindError(err, req, translator))
+// 			return
+// 		}
+// 		...
+// 	})
+type Validator struct {
+	validate *validator.Validate
+}
+
+var _ binding.StructValidator = (*Validator)(nil)
+
+// NewValidator creates a Validator with a new validator.Validate which uses `binding` tag, just like gin's default validator.
+func NewValidator() *Validator {
+	validate := validator.New()
+	validate.SetTagName("binding")
+	return &Validator{validate: validate}
+}
+
+// ValidateStruct implements binding.StructValidator, only struct and struct pointer will be validated.
+func (v *Validator) ValidateStruct(obj interface{}) error {
+	value := reflect.ValueOf(obj)
+	kind := value.Kind()
+	if kind == reflect.Ptr {
+		kind = value.Elem().Kind()
+	}
+	if kind != reflect.Struct {
+		return nil
+	}
+	return v.validate.Struct(obj)
+}
+
+// Engine implements binding.StructValidator, returns the underlying *validator.Validate.
+func (v *Validator) Engine() interface{} {
+	return v.validate
+}
+
+// AddBinding adds user defined binding to the validator, also see the global AddBinding.
+func (v *Validator) AddBinding(tag string, fn validator.Func) error {
+	return v.validate.RegisterValidation(tag, fn)
+}
+
+// AddStructBinding adds user defined struct level validation to the validator for given types, also see the global AddStructBinding.
+func (v *Validator) AddStructBinding(fn validator.StructLevelFunc, types ...interface{}) {
+	v.validate.RegisterStructValidation(fn, types...)
+}
+
+// AddCustomTypeFunc adds user defined custom type function to the validator for given types, also see the global AddCustomTypeFunc.
+func (v *Validator) AddCustomTypeFunc(fn validator.CustomTypeFunc, types ...interface{}) {
+	v.validate.RegisterCustomTypeFunc(fn, types...)
+}
+
+// AddTranslator adds user defined validator's translator to given ut.Translator using given tag, message and override, also see the
+// global AddTranslator. Note that the translator should be created by Validator.Translator.
+func (v *Validator) AddTranslator(translator ut.Translator, tag, message string, override bool) error {
+	fn := xvalidator.AddToTranslatorFunc(tag, message, override)
+	return v.validate.RegisterTranslation(tag, translator, fn, xvalidator.DefaultTranslateFunc())
+}
+
+// Translator applies and returns ut.Translator for the validator using given locales.Translator and xvalidator.TranslationRegisterHandler,
+// and sets up the translations of all the custom bindings in this package (such as `regexp`, `date` and `datetime`), also see the global
+// GetValidatorTranslator.
+func (v *Validator) Translator(locTranslator locales.Translator, registerFn xvalidator.TranslationRegisterHandler) (ut.Translator, error) {
+	translator, err := xvalidator.ApplyTranslator(v.validate, locTranslator, registerFn)
+	if err != nil {
+		return nil, err
+	}
+	if err = addAllBindingTranslators(translator, v.AddTranslator); err != nil {
+		return nil, err
+	}
+	return translator, nil
+}
+
+// validatorKey is the gin.Context key for the Validator attached by Validator.Middleware.
+const validatorKey = "xgin.validator"
+
+// Middleware creates a gin.HandlerFunc which attaches the validator to gin.Context, the attached validator will be used by ShouldBind and
+// other bind helpers. Use it for gin.Engine or gin.RouterGroup, the later attached one overrides the former.
+func (v *Validator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(validatorKey, v)
+		c.Next()
+	}
+}
+
+// GetValidator returns the Validator attached to gin.Context by Validator.Middleware.
+func GetValidator(c *gin.Context) (*Validator, bool) {
+	val, ok := c.Get(validatorKey)
+	if !ok {
+		return nil, false
+	}
+	v, ok := val.(*Validator)
+	return v, ok
+}
+
+// ============
+// bind helpers
+// ============
+
+// ShouldBindWith binds the request to obj using given binding.Binding, and validates obj using the Validator attached to gin.Context, or
+// the global binding.Validator if there is no Validator attached. Note that obj must be a non-nil pointer, and the builtin validation of
+// gin's bindings is skipped by wrapping binding.Validator, see bindWithoutValidation for details.
+func ShouldBindWith(c *gin.Context, obj interface{}, b binding.Binding) error {
+	if err := bindWithoutValidation(c.Request, obj, b); err != nil {
+		return err
+	}
+	return validateWith(c, obj)
+}
+
+// ShouldBind binds the request to obj using the binding chosen by method and content type, see binding.Default and ShouldBindWith.
+func ShouldBind(c *gin.Context, obj interface{}) error {
+	return ShouldBindWith(c, obj, binding.Default(c.Request.Method, c.ContentType()))
+}
+
+// ShouldBindJSON binds the request body to obj as json, see ShouldBindWith.
+func ShouldBindJSON(c *gin.Context, obj interface{}) error {
+	return ShouldBindWith(c, obj, binding.JSON)
+}
+
+// ShouldBindQuery binds the request query to obj, see ShouldBindWith.
+func ShouldBindQuery(c *gin.Context, obj interface{}) error {
+	return ShouldBindWith(c, obj, binding.Query)
+}
+
+// ShouldBindHeader binds the request header to obj, see ShouldBindWith.
+func ShouldBindHeader(c *gin.Context, obj interface{}) error {
+	return ShouldBindWith(c, obj, binding.Header)
+}
+
+// ShouldBindUri binds the route parameters to obj, see ShouldBindWith and UriBinding.
+func ShouldBindUri(c *gin.Context, obj interface{}) error {
+	return ShouldBindWith(c, obj, UriBinding(c))
+}
+
+// ShouldBindAll binds the route parameters, query, header and body (chosen by method and content type, only when the body is not empty)
+// to obj by their tags, each source only fills the fields tagged for it (`uri`, `form`, `header`, and `json` or `form` for body, fields
+// in embedded structs are included), so that a source can not overwrite other sources' fields by the field names, then applies the `default` tags by ApplyDefaults, and then validates obj once, so the validation errors from every source
+// are collected into one BindError. The decoding errors of each source (such as type mismatch) are also collected, in which case the Kind
+// and Err of the returned BindError come from the first error. The messages are translated by given translator, or the translator chosen
+// by TranslatorRegistry.Middleware if translator is nil. Note that nil will be returned if there is no error.
+//
+// Example:
+// 	type UpdateUserRequest struct {
+// 		ID    uint64 `uri:"id"       binding:"required"`
+// 		Token string `header:"X-Token" binding:"required"`
+// 		Force bool   `form:"force"`
+// 		Name  string `json:"name"    binding:"required,max=20"`
+// 	}
+// 	req := &UpdateUserRequest{}
+// 	if err := xgin.ShouldBindAll(c, req, nil); err != nil {
+// 		c.JSON(400, err) // {"kind": "validation", "message": "...", "fields": [...]}
+// 		return
+// 	}
+func ShouldBindAll(c *gin.Context, obj interface{}, translator ut.Translator) *BindError {
+	if translator == nil {
+		translator, _ = GetTranslator(c)
+	}
+	bindings := []binding.Binding{UriBinding(c), binding.Query, binding.Header}
+	if req := c.Request; req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0 {
+		bindings = append(bindings, binding.Default(req.Method, c.ContentType()))
+	}
+
+	errs := make([]*BindError, 0)
+	for _, b := range bindings {
+		if err := bindTaggedFields(c.Request, obj, b); err != nil {
+			errs = append(errs, ParseBindError(err, obj, translator))
+		}
+	}
+	if err := ApplyDefaults(obj); err != nil {
+		errs = append(errs, ParseBindError(err, obj, translator))
+	}
+	if err := validateWith(c, obj); err != nil {
+		errs = append(errs, ParseBindError(err, obj, translator))
+	}
+	return mergeBindErrors(errs)
+}
+
+// mergeBindErrors merges given BindError-s into one, the Kind and Err are from the first one, returns nil if errs is empty.
+func mergeBindErrors(errs []*BindError) *BindError {
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	merged := &BindError{Kind: errs[0].Kind, Err: errs[0].Err}
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Message)
+		merged.Fields = append(merged.Fields, err.Fields...)
+	}
+	merged.Message = strings.Join(messages, "; ")
+	return merged
+}
+
+// bindingTag returns the struct tag of fields which given binding.Binding fills in ShouldBindAll.
+func bindingTag(b binding.Binding) string {
+	switch b.Name() {
+	case "uri", "header", "xml", "yaml":
+		return b.Name()
+	case "query", "form", "form-urlencoded", "multipart/form-data":
+		return "form"
+	}
+	return "json"
+}
+
+// bindTaggedFields binds the request to the fields of obj which have the tag of given binding.Binding only, by binding to a view struct
+// which only contains these fields. Note that obj which is not a struct pointer will be bound directly.
+func bindTaggedFields(req *http.Request, obj interface{}, b binding.Binding) error {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return bindWithoutValidation(req, obj, b)
+	}
+	val = val.Elem()
+	fields, indexes := collectTaggedFields(val.Type(), bindingTag(b), nil, make(map[string]bool))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	view := reflect.New(reflect.StructOf(fields))
+	for i, index := range indexes {
+		view.Elem().Field(i).Set(val.FieldByIndex(index))
+	}
+	err := bindWithoutValidation(req, view.Interface(), b)
+	for i, index := range indexes {
+		val.FieldByIndex(index).Set(view.Elem().Field(i)) // also copy back when error occurs, just like binding to obj directly
+	}
+	return err
+}
+
+// collectTaggedFields collects the exported fields which have given tag from given struct type and its embedded structs, the outer field
+// takes precedence over the embedded one with the same name.
+func collectTaggedFields(typ reflect.Type, tag string, parent []int, names map[string]bool) ([]reflect.StructField, [][]int) {
+	fields, indexes := make([]reflect.StructField, 0), make([][]int, 0)
+	embedded := make([]reflect.StructField, 0)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, field)
+			continue
+		}
+		if value, ok := field.Tag.Lookup(tag); field.PkgPath != "" || !ok || value == "-" || names[field.Name] {
+			continue
+		}
+		names[field.Name] = true
+		index := append(append([]int{}, parent...), i)
+		fields = append(fields, reflect.StructField{Name: field.Name, Type: field.Type, Tag: field.Tag})
+		indexes = append(indexes, index)
+	}
+	for _, field := range embedded {
+		index := append(append([]int{}, parent...), field.Index...)
+		f, i := collectTaggedFields(field.Type, tag, index, names)
+		fields, indexes = append(fields, f...), append(indexes, i...)
+	}
+	return fields, indexes
+}
+
+// bindWithoutValidation binds the request to obj using given binding.Binding without validating obj.
+//
+// gin's bindings always validate using binding.Validator after decoding, and there is no exported way to decode only. So here
+// binding.Validator is wrapped by a skipValidator (only once, and again if it is replaced), and obj is marked as skipped while decoding,
+// then the wrapper skips validating obj and delegates other objects to the original validator. In this way, the bindings used by other
+// goroutines are still validated as before, and obj is validated later by the scoped Validator explicitly.
+func bindWithoutValidation(req *http.Request, obj interface{}, b binding.Binding) error {
+	key, ok := skipKey(obj)
+	if !ok {
+		return b.Bind(req, obj) // not a pointer, which can not be decoded to
+	}
+	skipValidatorMu.Lock()
+	if _, wrapped := binding.Validator.(*skipValidator); !wrapped {
+		binding.Validator = &skipValidator{origin: binding.Validator, skipped: make(map[uintptr]int)}
+	}
+	v := binding.Validator.(*skipValidator)
+	v.skipped[key]++
+	skipValidatorMu.Unlock()
+
+	defer func() {
+		skipValidatorMu.Lock()
+		if v.skipped[key]--; v.skipped[key] <= 0 {
+			delete(v.skipped, key)
+		}
+		skipValidatorMu.Unlock()
+	}()
+	return b.Bind(req, obj)
+}
+
+// skipValidatorMu guards binding.Validator wrapping and the skipped objects of skipValidator.
+var skipValidatorMu sync.Mutex
+
+// skipValidator is a binding.StructValidator which wraps the original binding.Validator, and skips validating the objects being decoded by
+// bindWithoutValidation.
+type skipValidator struct {
+	origin  binding.StructValidator
+	skipped map[uintptr]int
+}
+
+var _ binding.StructValidator = (*skipValidator)(nil)
+
+// ValidateStruct implements binding.StructValidator, it returns nil for the skipped objects, otherwise validates by the original one.
+func (s *skipValidator) ValidateStruct(obj interface{}) error {
+	if key, ok := skipKey(obj); ok {
+		skipValidatorMu.Lock()
+		skipped := s.skipped[key] > 0
+		skipValidatorMu.Unlock()
+		if skipped {
+			return nil
+		}
+	}
+	if s.origin == nil {
+		return nil
+	}
+	return s.origin.ValidateStruct(obj)
+}
+
+// Engine implements binding.StructValidator, it returns the engine of the original one.
+func (s *skipValidator) Engine() interface{} {
+	if s.origin == nil {
+		return nil
+	}
+	return s.origin.Engine()
+}
+
+// skipKey returns the address of given pointer obj as the key of skipValidator, returns false if obj is not a non-nil pointer.
+func skipKey(obj interface{}) (uintptr, bool) {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return 0, false
+	}
+	return val.Pointer(), true
+}
+
+// validateWith validates obj using the Validator attached to gin.Context or the global binding.Validator.
+func validateWith(c *gin.Context, obj interface{}) error {
+	if v, ok := GetValidator(c); ok {
+		return v.ValidateStruct(obj)
+	}
+	if binding.Validator == nil {
+		return nil
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
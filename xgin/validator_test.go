@@ -0,0 +1,290 @@
+package xgin
+
+import (
+	"github.com/Aoi-hosizora/ahlib-more/xvalidator"
+	"github.com/Aoi-hosizora/ahlib/xtesting"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	type testStruct struct {
+		Name string `json:"name" form:"name" binding:"required,strict_name"`
+	}
+	strict := NewValidator()
+	loose := NewValidator()
+	xtesting.Nil(t, strict.AddBinding("strict_name", func(fl validator.FieldLevel) bool { return len(fl.Field().String()) >= 5 }))
+	xtesting.Nil(t, loose.AddBinding("strict_name", func(fl validator.FieldLevel) bool { return true }))
+	xtesting.Equal(t, strict.Engine().(*validator.Validate) != loose.Engine().(*validator.Validate), true)
+	xtesting.Nil(t, strict.ValidateStruct(1))
+	xtesting.NotNil(t, strict.ValidateStruct(&testStruct{Name: "abc"}))
+	xtesting.Nil(t, loose.ValidateStruct(testStruct{Name: "abc"}))
+
+	// translator
+	translator, err := strict.Translator(xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+	xtesting.Nil(t, strict.AddTranslator(translator, "strict_name", "{0} is too short", true))
+	xtesting.Nil(t, strict.AddBinding("regexp", xvalidator.ParamRegexpValidator()))
+	type regexpStruct struct {
+		Code string `binding:"regexp=^[0-9]+$"`
+	}
+	err = strict.ValidateStruct(&regexpStruct{Code: "x"})
+	xtesting.Equal(t, err.(validator.ValidationErrors)[0].Translate(translator), "Code must matches regexp /^[0-9]+$/")
+
+	// two engines
+	gin.SetMode(gin.ReleaseMode)
+	handler := func(c *gin.Context) {
+		obj := &testStruct{}
+		if err := ShouldBind(c, obj); err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		c.String(200, obj.Name)
+	}
+	strictApp, looseApp := gin.New(), gin.New()
+	strictApp.Use(strict.Middleware())
+	looseApp.Use(loose.Middleware())
+	strictApp.POST("/", handler)
+	looseApp.POST("/", handler)
+	plainApp := gin.New()
+	plainApp.GET("/", handler)
+	plainApp.Group("", strict.Middleware()).GET("/strict", handler)
+
+	for _, tc := range []struct {
+		giveApp  *gin.Engine
+		giveReq  string
+		wantCode int
+		wantBody string
+	}{
+		{strictApp, `{"name": "abc"}`, 400, "Key: 'testStruct.Name' Error:Field validation for 'Name' failed on the 'strict_name' tag"},
+		{strictApp, `{"name": "abcdef"}`, 200, "abcdef"},
+		{looseApp, `{"name": "abc"}`, 200, "abc"},
+		{looseApp, `{"name": ""}`, 400, "Key: 'testStruct.Name' Error:Field validation for 'Name' failed on the 'required' tag"},
+		{looseApp, `{"name": `, 400, "unexpected EOF"},
+	} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tc.giveReq))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		tc.giveApp.ServeHTTP(w, req)
+		xtesting.Equal(t, w.Code, tc.wantCode)
+		xtesting.Equal(t, w.Body.String(), tc.wantBody)
+	}
+
+	// route group and global fallback
+	xtesting.Panic(t, func() { _ = binding.Validator.ValidateStruct(&testStruct{Name: "abc"}) }) // undefined validation function
+	for _, tc := range []struct {
+		givePath string
+		wantCode int
+	}{
+		{"/strict?name=abc", 400},
+		{"/strict?name=abcdef", 200},
+	} {
+		w := httptest.NewRecorder()
+		plainApp.ServeHTTP(w, httptest.NewRequest("GET", tc.givePath, nil))
+		xtesting.Equal(t, w.Code, tc.wantCode)
+	}
+	oldValidator := binding.Validator
+	binding.Validator = nil
+	w := httptest.NewRecorder()
+	plainApp.ServeHTTP(w, httptest.NewRequest("GET", "/?name=a", nil))
+	xtesting.Equal(t, w.Code, 200)
+	binding.Validator = loose
+	w = httptest.NewRecorder()
+	plainApp.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	xtesting.Equal(t, w.Code, 400)
+	binding.Validator = oldValidator
+
+	// bind helpers
+	type uriStruct struct {
+		ID uint64 `uri:"id" binding:"required,strict_name"`
+	}
+	type headerStruct struct {
+		Token string `header:"X-Token" binding:"required"`
+	}
+	type queryStruct struct {
+		Page int `form:"page" binding:"min=1"`
+	}
+	ar := NewAppRouter(plainApp, plainApp.Group("v1", loose.Middleware()))
+	ar.GET(":id", func(c *gin.Context) {
+		_, ok := GetValidator(c)
+		xtesting.True(t, ok)
+		uriObj, headerObj, queryObj := &uriStruct{}, &headerStruct{}, &queryStruct{}
+		for _, err := range []error{ShouldBindUri(c, uriObj), ShouldBindHeader(c, headerObj), ShouldBindQuery(c, queryObj)} {
+			if err != nil {
+				c.String(400, err.Error())
+				return
+			}
+		}
+		c.String(200, "%d %s %d", uriObj.ID, headerObj.Token, queryObj.Page)
+	})
+	ar.Register()
+	for _, tc := range []struct {
+		givePath   string
+		giveHeader string
+		wantCode   int
+		wantBody   string
+	}{
+		{"/v1/x", "", 400, `strconv.ParseUint: parsing "x": invalid syntax`},
+		{"/v1/1", "", 400, "Key: 'headerStruct.Token' Error:Field validation for 'Token' failed on the 'required' tag"},
+		{"/v1/1", "x", 400, "Key: 'queryStruct.Page' Error:Field validation for 'Page' failed on the 'min' tag"},
+		{"/v1/1?page=2", "x", 200, "1 x 2"},
+	} {
+		req := httptest.NewRequest("GET", tc.givePath, nil)
+		req.Header.Set("X-Token", tc.giveHeader)
+		w := httptest.NewRecorder()
+		plainApp.ServeHTTP(w, req)
+		xtesting.Equal(t, w.Code, tc.wantCode)
+		xtesting.Equal(t, w.Body.String(), tc.wantBody)
+	}
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, ok := GetValidator(ctx)
+	xtesting.False(t, ok)
+	ctx.Set(validatorKey, "")
+	_, ok = GetValidator(ctx)
+	xtesting.False(t, ok)
+
+	// builtin validation skipped while decoding
+	type jsonStruct struct {
+		Name string `json:"name" binding:"required"`
+	}
+	oldValidator = binding.Validator
+	defer func() { binding.Validator = oldValidator }()
+	binding.Validator = loose
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name": ""}`))
+	xtesting.Nil(t, bindWithoutValidation(req, &jsonStruct{}, binding.JSON))
+	wrapped, ok := binding.Validator.(*skipValidator)
+	xtesting.True(t, ok)
+	xtesting.Equal(t, wrapped.origin, binding.StructValidator(loose))
+	xtesting.Equal(t, len(wrapped.skipped), 0)
+	xtesting.NotNil(t, binding.Validator.ValidateStruct(&jsonStruct{}))
+	xtesting.Equal(t, binding.Validator.Engine(), loose.Engine())
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"name": ""}`))
+	xtesting.NotNil(t, binding.JSON.Bind(req, &jsonStruct{})) // other bindings are still validated
+	binding.Validator = nil
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"name": ""}`))
+	xtesting.Nil(t, bindWithoutValidation(req, &jsonStruct{}, binding.JSON))
+	xtesting.Nil(t, binding.Validator.ValidateStruct(&jsonStruct{}))
+	xtesting.Nil(t, binding.Validator.Engine())
+}
+
+func TestShouldBindAll(t *testing.T) {
+	type testStruct struct {
+		ID    uint64 `uri:"id"        binding:"required,max=100"`
+		Token string `header:"X-Token" binding:"required"`
+		Force bool   `form:"force"`
+		Page  int32  `form:"page"     binding:"min=1" default:"1"`
+		Name  string `json:"name"     form:"name" binding:"required,max=5"`
+	}
+	registry := NewTranslatorRegistry()
+	_, err := registry.Register(xvalidator.EnLocaleTranslator(), xvalidator.EnTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+	zh, err := registry.Register(xvalidator.ZhLocaleTranslator(), xvalidator.ZhTranslationRegisterFunc())
+	xtesting.Nil(t, err)
+
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	ar := NewAppRouter(app, app.Group("", registry.Middleware("lang")))
+	handler := func(translator ut.Translator) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			obj := &testStruct{}
+			if err := ShouldBindAll(c, obj, translator); err != nil {
+				c.JSON(400, err)
+				return
+			}
+			c.JSON(200, obj)
+		}
+	}
+	ar.PUT("users/:id", handler(nil))
+	ar.GET("users/:id", handler(nil))
+	ar.PUT("zh/:id", handler(zh))
+	ar.Register()
+
+	for _, tc := range []struct {
+		giveMethod string
+		givePath   string
+		giveToken  string
+		giveType   string
+		giveBody   string
+		wantCode   int
+		wantBody   string
+	}{
+		{"PUT", "/users/1?force=true", "x", "application/json", `{"name": "abc"}`, 200,
+			`{"ID":1,"Token":"x","Force":true,"Page":1,"name":"abc"}`},
+		{"PUT", "/users/1?page=2", "x", "application/x-www-form-urlencoded", `name=abc`, 200,
+			`{"ID":1,"Token":"x","Force":false,"Page":2,"name":"abc"}`},
+		{"PUT", "/users/101", "", "application/json", `{"name": "abcdef"}`, 400,
+			`{"kind":"validation","message":"ID must be 100 or less; Token is a required field; Name must be a maximum of 5 characters in length","fields":[` +
+				`{"field":"id","tag":"max","param":"100","message":"ID must be 100 or less"},` +
+				`{"field":"X-Token","tag":"required","param":"","message":"Token is a required field"},` +
+				`{"field":"name","tag":"max","param":"5","message":"Name must be a maximum of 5 characters in length"}]}`},
+		{"GET", "/users/1?lang=zh", "", "", ``, 400,
+			`{"kind":"validation","message":"Token为必填字段; Name为必填字段","fields":[` +
+				`{"field":"X-Token","tag":"required","param":"","message":"Token为必填字段"},` +
+				`{"field":"name","tag":"required","param":"","message":"Name为必填字段"}]}`},
+		{"PUT", "/zh/x?page=y", "x", "application/json", `{"name": ""}`, 400,
+			`{"kind":"type_mismatch","message":"strconv.ParseUint: parsing \"x\": invalid syntax; strconv.ParseInt: parsing \"y\": invalid syntax; ` +
+				`ID为必填字段; Name为必填字段","fields":[` +
+				`{"field":"id","tag":"required","param":"","message":"ID为必填字段"},` +
+				`{"field":"name","tag":"required","param":"","message":"Name为必填字段"}]}`},
+		{"PUT", "/users/1?ID=2&Token=y&id=3", "x", "application/json", `{"name": "a", "id": 4, "ID": 5, "Token": "z", "X-Token": "z"}`, 200,
+			`{"ID":1,"Token":"x","Force":false,"Page":1,"name":"a"}`},
+		{"PUT", "/users/1?Token=y&X-Token=y", "", "application/json", `{"name": "a", "Token": "z"}`, 400,
+			`{"kind":"validation","message":"Token is a required field","fields":[{"field":"X-Token","tag":"required","param":"","message":"Token is a required field"}]}`},
+		{"PUT", "/users/1?name=abc", "x", "application/x-www-form-urlencoded", `ID=2&id=3&Token=z`, 200,
+			`{"ID":1,"Token":"x","Force":false,"Page":1,"name":"abc"}`},
+		{"PUT", "/users/1", "x", "application/json", `{"name": `, 400,
+			`{"kind":"syntax","message":"unexpected EOF; Name is a required field","fields":[{"field":"name","tag":"required","param":"","message":"Name is a required field"}]}`},
+	} {
+		req := httptest.NewRequest(tc.giveMethod, tc.givePath, strings.NewReader(tc.giveBody))
+		req.Header.Set("X-Token", tc.giveToken)
+		req.Header.Set("Content-Type", tc.giveType)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		xtesting.Equal(t, w.Code, tc.wantCode)
+		xtesting.Equal(t, w.Body.String(), tc.wantBody)
+	}
+
+	xtesting.Nil(t, mergeBindErrors(nil))
+
+	// tagged fields
+	type innerStruct struct {
+		ID   uint64 `uri:"id"`
+		Name string `form:"name"`
+	}
+	type outerStruct struct {
+		innerStruct
+		Name    string `form:"name"`
+		Ignored string `form:"-"`
+		private string `form:"private"`
+	}
+	fields, indexes := collectTaggedFields(reflect.TypeOf(outerStruct{}), "form", nil, make(map[string]bool))
+	xtesting.Equal(t, len(fields), 1)
+	xtesting.Equal(t, indexes, [][]int{{1}})
+	fields, indexes = collectTaggedFields(reflect.TypeOf(outerStruct{}), "uri", nil, make(map[string]bool))
+	xtesting.Equal(t, len(fields), 1)
+	xtesting.Equal(t, indexes, [][]int{{0, 0}})
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	for _, tc := range []struct {
+		give binding.Binding
+		want string
+	}{
+		{binding.Query, "form"}, {binding.FormPost, "form"}, {binding.FormMultipart, "form"}, {binding.Header, "header"},
+		{UriBinding(ctx), "uri"}, {binding.JSON, "json"}, {binding.XML, "xml"}, {binding.MsgPack, "json"},
+	} {
+		xtesting.Equal(t, bindingTag(tc.give), tc.want)
+	}
+	ctx.Request = httptest.NewRequest("GET", "/?name=x", nil)
+	obj := &outerStruct{}
+	xtesting.Nil(t, bindTaggedFields(ctx.Request, obj, binding.Query))
+	xtesting.Equal(t, obj, &outerStruct{Name: "x"})
+	xtesting.Nil(t, bindTaggedFields(ctx.Request, &struct{}{}, binding.Query))
+	m := map[string]interface{}{}
+	xtesting.NotNil(t, bindTaggedFields(ctx.Request, &m, binding.JSON))
+}
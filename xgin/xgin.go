@@ -1,7 +1,10 @@
 package xgin
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/Aoi-hosizora/ahlib-more/xvalidator"
 	"github.com/Aoi-hosizora/ahlib/xtime"
 	"github.com/gin-gonic/gin"
@@ -9,8 +12,14 @@ import (
 	"github.com/go-playground/locales"
 	"github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/http/httputil"
-	"net/http/pprof"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -24,29 +33,51 @@ type dumpRequestOptions struct {
 	ignoreHeaders []string
 	secretHeaders []string
 	secretReplace string
+	secretPartial bool
+	bodyLimit     int64
+	secretFields  []string
+
+	retainMatchers []headerMatcher  // compiled from retainHeaders
+	ignoreMatchers []headerMatcher  // compiled from ignoreHeaders
+	secretMatchers []headerMatcher  // compiled from secretHeaders
+	secretRegexps  []*regexp.Regexp // compiled from secretFields
 }
 
 // DumpRequestOption represents an option for DumpRequest, can be created by WithXXX functions.
+//
+// Note that header names used in WithRetainHeaders, WithIgnoreHeaders and WithSecretHeaders are matched case-insensitively, and
+// can be in the following formats:
+// 	Authorization // normal header name, such as "Authorization", "authorization"
+// 	X-Internal-*  // glob pattern, such as "X-Internal-Id", "x-internal-token", see path.Match
+// 	/^X-(A|B)$/   // regexp pattern enclosed in slashes, such as "X-A", "x-b", see regexp.Regexp
+//
+// These patterns are compiled once when the options are created, and the option functions panic when given pattern is invalid.
 type DumpRequestOption func(*dumpRequestOptions)
 
 // WithRetainHeaders creates a DumpRequestOption for retained header. Set this option will make DumpRequest ignore the WithIgnoreHeaders option.
 func WithRetainHeaders(headers ...string) DumpRequestOption {
+	matchers := newHeaderMatchers(headers)
 	return func(o *dumpRequestOptions) {
 		o.retainHeaders = headers
+		o.retainMatchers = matchers
 	}
 }
 
 // WithIgnoreHeaders creates a DumpRequestOption for ignore headers. This option will be ignored when WithRetainHeaders is used in DumpRequest.
 func WithIgnoreHeaders(headers ...string) DumpRequestOption {
+	matchers := newHeaderMatchers(headers)
 	return func(o *dumpRequestOptions) {
 		o.ignoreHeaders = headers
+		o.ignoreMatchers = matchers
 	}
 }
 
 // WithSecretHeaders creates a DumpRequestOption for secret headers, such as Authorization.
 func WithSecretHeaders(headers ...string) DumpRequestOption {
+	matchers := newHeaderMatchers(headers)
 	return func(o *dumpRequestOptions) {
 		o.secretHeaders = headers
+		o.secretMatchers = matchers
 	}
 }
 
@@ -57,13 +88,37 @@ func WithSecretReplace(secret string) DumpRequestOption {
 	}
 }
 
-// DumpRequest dumps and formats http.Request from gin.Context to string slice, using given DumpRequestOption-s. The first element must be request line
-// "METHOD /ENDPOINT HTTP/1.1", and the remaining elements are the request headers "XXX: YYY", returns an empty slice when using nil gin.Context.
-func DumpRequest(c *gin.Context, options ...DumpRequestOption) []string {
-	if c == nil {
-		return []string{}
+// WithSecretPartialMask creates a DumpRequestOption for partial-mask mode of secret headers, defaults to false. If this option is set to true,
+// the auth scheme of secret headers will be kept, such as "Authorization: Bearer *", and the value without scheme will still be masked entirely.
+func WithSecretPartialMask(partial bool) DumpRequestOption {
+	return func(o *dumpRequestOptions) {
+		o.secretPartial = partial
+	}
+}
+
+// WithBodyLimit creates a DumpRequestOption for capturing request body, at most limit bytes will be dumped, defaults to 0, which means body will
+// not be dumped. Note that the body read will be put back to http.Request, so handlers can still read the whole body.
+func WithBodyLimit(limit int64) DumpRequestOption {
+	return func(o *dumpRequestOptions) {
+		o.bodyLimit = limit
 	}
+}
+
+// WithSecretFields creates a DumpRequestOption for secret body fields in json or form body, such as password and token, these fields' values
+// will be replaced by the string set by WithSecretReplace.
+func WithSecretFields(fields ...string) DumpRequestOption {
+	regexps := make([]*regexp.Regexp, 0, len(fields))
+	for _, field := range fields {
+		regexps = append(regexps, regexp.MustCompile(`("`+regexp.QuoteMeta(field)+`"\s*:\s*)("(?:[^"\\]|\\.)*"|[^\s,}\]]+)`))
+	}
+	return func(o *dumpRequestOptions) {
+		o.secretFields = fields
+		o.secretRegexps = regexps
+	}
+}
 
+// newDumpRequestOptions creates a dumpRequestOptions from given DumpRequestOption-s.
+func newDumpRequestOptions(options []DumpRequestOption) *dumpRequestOptions {
 	opt := &dumpRequestOptions{
 		secretReplace: "*",
 	}
@@ -72,7 +127,101 @@ func DumpRequest(c *gin.Context, options ...DumpRequestOption) []string {
 			op(opt)
 		}
 	}
+	return opt
+}
+
+const (
+	panicInvalidHeaderPattern = "xgin: invalid header pattern '%s': %v"
+)
+
+// headerMatcher represents a header name matcher, which is used to check header name case-insensitively.
+type headerMatcher func(name string) bool
+
+// newHeaderMatchers creates headerMatcher-s from given header names or patterns, panics when pattern is invalid.
+func newHeaderMatchers(patterns []string) []headerMatcher {
+	matchers := make([]headerMatcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern := pattern
+		switch {
+		case len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/"):
+			// regexp pattern
+			re, err := regexp.Compile("(?i)" + pattern[1:len(pattern)-1])
+			if err != nil {
+				panic(fmt.Sprintf(panicInvalidHeaderPattern, pattern, err))
+			}
+			matchers = append(matchers, re.MatchString)
+		case strings.ContainsAny(pattern, "*?["):
+			// glob pattern
+			lower := strings.ToLower(pattern)
+			if _, err := path.Match(lower, ""); err != nil {
+				panic(fmt.Sprintf(panicInvalidHeaderPattern, pattern, err))
+			}
+			matchers = append(matchers, func(name string) bool {
+				ok, _ := path.Match(lower, strings.ToLower(name))
+				return ok
+			})
+		default:
+			// header name
+			matchers = append(matchers, func(name string) bool {
+				return strings.EqualFold(pattern, name)
+			})
+		}
+	}
+	return matchers
+}
 
+// matchHeader checks whether given header name matches one of the headerMatcher-s.
+func matchHeader(matchers []headerMatcher, name string) bool {
+	for _, matcher := range matchers {
+		if matcher(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterHeader checks and masks given header line "XXX: YYY" using dumpRequestOptions, returns false if the header should be ignored.
+func (o *dumpRequestOptions) filterHeader(param string) (string, bool) {
+	kv := strings.SplitN(param, ":", 2)
+	if len(kv) != 2 {
+		return param, true
+	}
+	name, value := http.CanonicalHeaderKey(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+
+	// ignore
+	if len(o.retainMatchers) != 0 {
+		// use retainHeaders
+		if !matchHeader(o.retainMatchers, name) {
+			return "", false
+		}
+	} else {
+		// use ignoreHeaders
+		if matchHeader(o.ignoreMatchers, name) {
+			return "", false
+		}
+	}
+
+	// secret
+	if matchHeader(o.secretMatchers, name) {
+		if o.secretPartial {
+			if sp := strings.IndexByte(value, ' '); sp > 0 {
+				return name + ": " + value[:sp] + " " + o.secretReplace, true // Bearer *
+			}
+		}
+		return name + ": " + o.secretReplace, true
+	}
+	return name + ": " + value, true
+}
+
+// DumpRequest dumps and formats http.Request from gin.Context to string slice, using given DumpRequestOption-s. The first element must be request line
+// "METHOD /ENDPOINT HTTP/1.1", and the remaining elements are the request headers "XXX: YYY", returns an empty slice when using nil gin.Context.
+// If WithBodyLimit is used and the request body is not empty, the last two elements will be an empty string and the (maybe truncated) body.
+func DumpRequest(c *gin.Context, options ...DumpRequestOption) []string {
+	if c == nil {
+		return []string{}
+	}
+
+	opt := newDumpRequestOptions(options)
 	bs, _ := httputil.DumpRequest(c.Request, false) // ignore error
 	params := strings.Split(string(bs), "\r\n")     // split by \r\n
 	result := make([]string, 0, len(params))
@@ -88,151 +237,357 @@ func DumpRequest(c *gin.Context, options ...DumpRequestOption) []string {
 			continue
 		}
 
-		// ignore
-		if len(opt.retainHeaders) != 0 {
-			// use retainHeaders
-			retained := false
-			for _, header := range opt.retainHeaders {
-				if strings.HasPrefix(param, header+": ") {
-					retained = true
-					break
-				}
-			}
-			if !retained {
-				continue
-			}
-		} else {
-			// use ignoreHeaders
-			ignored := false
-			for _, header := range opt.ignoreHeaders {
-				if strings.HasPrefix(param, header+": ") {
-					ignored = true
-					break
-				}
-			}
-			if ignored {
-				continue
-			}
+		// ignore and secret, and append
+		if header, ok := opt.filterHeader(param); ok {
+			result = append(result, header)
 		}
+	}
 
-		// secret
-		for _, header := range opt.secretHeaders {
-			if strings.HasPrefix(param, header+": ") {
-				param = header + ": " + opt.secretReplace
-				break
-			}
+	// body
+	if opt.bodyLimit > 0 {
+		if body := dumpRequestBody(c.Request, opt); body != "" {
+			result = append(result, "", body) // blank line and body
 		}
-
-		// append
-		result = append(result, param)
 	}
 
 	return result
 }
 
-// =====
-// pprof
-// =====
+// DumpCurlCommand dumps and formats http.Request from gin.Context to a curl command line, using given DumpRequestOption-s, returns an empty string
+// when using nil gin.Context. Note that the header options are applied to headers, and the body will be added as --data-raw only when WithBodyLimit
+// is used, which may be masked, and a truncated body will never be added, a shell comment will be appended instead.
+//
+// Example:
+// 	xgin.DumpCurlCommand(c, xgin.WithSecretHeaders("Authorization"), xgin.WithBodyLimit(1024))
+// 	// curl -X POST 'http://localhost:12345/login?a=b' -H 'Authorization: *' -H 'Content-Type: application/json' --data-raw '{"username": "x"}'
+func DumpCurlCommand(c *gin.Context, options ...DumpRequestOption) string {
+	if c == nil {
+		return ""
+	}
 
-// PprofWrap adds several routes from package `net/http/pprof` to gin.Engine. Reference from https://github.com/DeanThompson/ginpprof.
-func PprofWrap(router *gin.Engine) {
-	for _, r := range []struct {
-		method  string
-		path    string
-		handler gin.HandlerFunc
-	}{
-		{"GET", "/debug/pprof/", indexHandler()},
-		{"GET", "/debug/pprof/heap", heapHandler()},
-		{"GET", "/debug/pprof/goroutine", goroutineHandler()},
-		{"GET", "/debug/pprof/allocs", allocsHandler()},
-		{"GET", "/debug/pprof/block", blockHandler()},
-		{"GET", "/debug/pprof/threadcreate", threadCreateHandler()},
-		{"GET", "/debug/pprof/cmdline", cmdlineHandler()},
-		{"GET", "/debug/pprof/profile", profileHandler()},
-		{"GET", "/debug/pprof/symbol", symbolHandler()},
-		{"POST", "/debug/pprof/symbol", symbolHandler()},
-		{"GET", "/debug/pprof/trace", traceHandler()},
-		{"GET", "/debug/pprof/mutex", mutexHandler()},
-	} {
-		router.Handle(r.method, r.path, r.handler) // use path directly
+	opt := newDumpRequestOptions(options)
+	req := c.Request
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	params := []string{"curl", "-X", shellQuote(req.Method), shellQuote(scheme + "://" + req.Host + req.URL.RequestURI())}
+	header := req.Header.Clone()
+	header.Del("Content-Length") // calculated by curl
+	for _, param := range dumpHeaders(header, opt) {
+		params = append(params, "-H", shellQuote(param))
 	}
+	if opt.bodyLimit > 0 {
+		body, truncated := readRequestBody(req, opt)
+		switch {
+		case truncated:
+			params = append(params, fmt.Sprintf("# body is omitted, because it is larger than %d bytes", opt.bodyLimit))
+		case body != "":
+			params = append(params, "--data-raw", shellQuote(body))
+		}
+	}
+	return strings.Join(params, " ")
 }
 
-// indexHandler is used for GET /debug/pprof to pprof.
-func indexHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Index(ctx.Writer, ctx.Request)
+// shellQuote quotes given string using single quote for posix shell, returns the string directly if no special characters are included.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r)) {
+			safe = false
+			break
+		}
 	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
-// heapHandler is for GET /debug/pprof/heap to pprof.
-func heapHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Handler("heap").ServeHTTP(ctx.Writer, ctx.Request)
+// dumpRequestBody reads at most dumpRequestOptions.bodyLimit bytes from http.Request's body, puts the read bytes back and returns the masked body.
+func dumpRequestBody(req *http.Request, opt *dumpRequestOptions) string {
+	body, truncated := readRequestBody(req, opt)
+	if truncated {
+		body += "..."
 	}
+	return body
 }
 
-// goroutineHandler is used for GET /debug/pprof/goroutine to pprof.
-func goroutineHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Handler("goroutine").ServeHTTP(ctx.Writer, ctx.Request)
+// readRequestBody reads at most opt.bodyLimit bytes of body from http.Request and masks it, returns true if the body is truncated, note that the
+// read body will be put back to http.Request.
+func readRequestBody(req *http.Request, opt *dumpRequestOptions) (string, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", false
+	}
+
+	bs, _ := ioutil.ReadAll(io.LimitReader(req.Body, opt.bodyLimit+1)) // ignore error
+	req.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(bs), req.Body), Closer: req.Body}
+	truncated := int64(len(bs)) > opt.bodyLimit
+	if truncated {
+		bs = bs[:opt.bodyLimit]
+	}
+
+	body := string(bs)
+	if len(opt.secretFields) != 0 {
+		body = maskBodyFields(body, req.Header.Get("Content-Type"), opt)
 	}
+	return body, truncated
 }
 
-// allocsHandler is used for GET /debug/pprof/allocs to pprof.
-func allocsHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Handler("allocs").ServeHTTP(ctx.Writer, ctx.Request)
+// readCloser combines an io.Reader and an io.Closer to io.ReadCloser, used to put the read body back to http.Request.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// maskBodyFields replaces the secret fields' values in json or form body to secret string, other bodies will be returned directly. Note
+// that the secret fields in json body are masked recursively (objects and arrays are masked entirely) and other parts are kept as they are,
+// the regexps are used to mask only when the body is not a valid json, such as truncated body.
+func maskBodyFields(body, contentType string, opt *dumpRequestOptions) string {
+	switch {
+	case strings.Contains(contentType, binding.MIMEPOSTForm):
+		// a=b&password=c => a=b&password=*
+		pairs := strings.Split(body, "&")
+		for idx, pair := range pairs {
+			kv := strings.SplitN(pair, "=", 2)
+			key, err := url.QueryUnescape(kv[0])
+			if err != nil {
+				continue
+			}
+			for _, field := range opt.secretFields {
+				if key == field {
+					pairs[idx] = kv[0] + "=" + opt.secretReplace
+					break
+				}
+			}
+		}
+		return strings.Join(pairs, "&")
+	case strings.Contains(contentType, binding.MIMEJSON):
+		// {"a": "b", "password": {"c": "d"}} => {"a": "b", "password": "*"}
+		secret := quoteJSONString(opt.secretReplace) // "*"
+		if json.Valid([]byte(body)) {
+			m := &jsonMasker{data: body, fields: opt.secretFields}
+			m.value(false)
+			sb := strings.Builder{}
+			last := 0
+			for _, span := range m.spans {
+				sb.WriteString(body[last:span[0]])
+				sb.WriteString(secret)
+				last = span[1]
+			}
+			sb.WriteString(body[last:])
+			return sb.String()
+		}
+		replace := "${1}" + strings.ReplaceAll(secret, "$", "$$") // escape $ in secret
+		for _, re := range opt.secretRegexps {
+			body = re.ReplaceAllString(body, replace)
+		}
+		return body
+	default:
+		return body
 	}
 }
 
-// blockHandler is used for GET /debug/pprof/block to pprof.
-func blockHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Handler("block").ServeHTTP(ctx.Writer, ctx.Request)
+// quoteJSONString quotes given string as a json string, html characters are not escaped.
+func quoteJSONString(s string) string {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)                         // never fails for string
+	return strings.TrimSuffix(buf.String(), "\n") // remove the trailing newline
+}
+
+// jsonMasker scans a valid json and records the spans of secret fields' values, which is used to mask the json without changing its format.
+type jsonMasker struct {
+	data   string
+	pos    int
+	fields []string
+	spans  [][2]int // [start, end) of the values to be masked
+}
+
+// skipSpaces skips the whitespaces from current position.
+func (m *jsonMasker) skipSpaces() {
+	for m.pos < len(m.data) && strings.IndexByte(" \t\r\n", m.data[m.pos]) != -1 {
+		m.pos++
 	}
 }
 
-// threadCreateHandler is used for GET /debug/pprof/threadcreate to pprof.
-func threadCreateHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Handler("threadcreate").ServeHTTP(ctx.Writer, ctx.Request)
+// value scans a json value from current position, the spans inside the value will not be recorded if skip is true.
+func (m *jsonMasker) value(skip bool) {
+	m.skipSpaces()
+	switch m.data[m.pos] {
+	case '{', '[':
+		end := byte('}')
+		if m.data[m.pos] == '[' {
+			end = ']'
+		}
+		m.pos++
+		for {
+			m.skipSpaces()
+			if m.data[m.pos] == end {
+				m.pos++
+				return
+			}
+			mask := false
+			if end == '}' {
+				keyStart := m.pos
+				m.str()
+				var key string
+				_ = json.Unmarshal([]byte(m.data[keyStart:m.pos]), &key) // never fails for valid json
+				m.skipSpaces()
+				m.pos++ // :
+				for _, field := range m.fields {
+					if key == field {
+						mask = !skip
+						break
+					}
+				}
+			}
+			m.skipSpaces()
+			start := m.pos
+			m.value(skip || mask)
+			if mask {
+				m.spans = append(m.spans, [2]int{start, m.pos})
+			}
+			m.skipSpaces()
+			if m.data[m.pos] == ',' {
+				m.pos++
+			}
+		}
+	case '"':
+		m.str()
+	default:
+		for m.pos < len(m.data) && strings.IndexByte(",]} \t\r\n", m.data[m.pos]) == -1 {
+			m.pos++ // number, true, false and null
+		}
 	}
 }
 
-// cmdlineHandler is used for GET /debug/pprof/cmdline to pprof.
-func cmdlineHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Cmdline(ctx.Writer, ctx.Request)
+// str scans a json string from current position.
+func (m *jsonMasker) str() {
+	for m.pos++; m.data[m.pos] != '"'; m.pos++ {
+		if m.data[m.pos] == '\\' {
+			m.pos++ // escaped character
+		}
 	}
+	m.pos++
+}
+
+// =============
+// dump response
+// =============
+
+// DumpedResponse represents a dumped http response, generated by DumpResponse.
+type DumpedResponse struct {
+	Status  int      `json:"status"`  // response status code
+	Headers []string `json:"headers"` // response headers, in "XXX: YYY" format and sorted by name
+	Body    string   `json:"body"`    // response body, maybe truncated, only be set when WithBodyLimit is used
 }
 
-// profileHandler is used for GET /debug/pprof/profile to pprof.
-func profileHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Profile(ctx.Writer, ctx.Request)
+// dumpedResponseKey is the gin.Context key for DumpedResponse, used in DumpResponse and GetDumpedResponse.
+const dumpedResponseKey = "xgin.dumped_response"
+
+// DumpResponse creates a gin.HandlerFunc that wraps gin.Context's writer and dumps the response, using given callback (can be nil) and DumpRequestOption-s.
+// The dumped DumpedResponse will be passed to the callback, and can also be got by GetDumpedResponse, or be logged by LogToLogrus using WithDumpedResponse.
+// Note that WithRetainHeaders, WithIgnoreHeaders, WithSecretHeaders, WithSecretReplace, WithBodyLimit and WithSecretFields are all supported.
+//
+// Example:
+// 	app.Use(func(c *gin.Context) {
+// 		start := time.Now()
+// 		c.Next()
+// 		xgin.LogToLogrus(logger, c, start, time.Now(), xgin.WithDumpedResponse(c))
+// 	})
+// 	app.Use(xgin.DumpResponse(nil, xgin.WithSecretHeaders("Set-Cookie"), xgin.WithBodyLimit(1024)))
+func DumpResponse(callback func(c *gin.Context, resp *DumpedResponse), options ...DumpRequestOption) gin.HandlerFunc {
+	opt := newDumpRequestOptions(options)
+	return func(c *gin.Context) {
+		writer := &dumpResponseWriter{ResponseWriter: c.Writer, limit: opt.bodyLimit}
+		c.Writer = writer
+		defer func() { c.Writer = writer.ResponseWriter }()
+		c.Next()
+
+		resp := &DumpedResponse{Status: writer.Status()}
+		resp.Headers = dumpHeaders(writer.Header(), opt)
+		if opt.bodyLimit > 0 {
+			resp.Body = writer.body.String()
+			if len(opt.secretFields) != 0 {
+				resp.Body = maskBodyFields(resp.Body, writer.Header().Get("Content-Type"), opt)
+			}
+			if writer.truncated {
+				resp.Body += "..."
+			}
+		}
+
+		c.Set(dumpedResponseKey, resp)
+		if callback != nil {
+			callback(c, resp)
+		}
 	}
 }
 
-// symbolHandler is used for GET, POST /debug/pprof/symbol to pprof.
-func symbolHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Symbol(ctx.Writer, ctx.Request)
+// GetDumpedResponse returns the DumpedResponse stored in gin.Context by DumpResponse, returns false if not found.
+func GetDumpedResponse(c *gin.Context) (*DumpedResponse, bool) {
+	v, ok := c.Get(dumpedResponseKey)
+	if !ok {
+		return nil, false
 	}
+	resp, ok := v.(*DumpedResponse)
+	return resp, ok
 }
 
-// traceHandler is used for GET /debug/pprof/trace to pprof.
-func traceHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Trace(ctx.Writer, ctx.Request)
+// dumpResponseWriter is a gin.ResponseWriter which copies at most limit bytes of the written body, used in DumpResponse.
+type dumpResponseWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+// capture copies given bytes to body buffer, and records whether the body is truncated.
+func (w *dumpResponseWriter) capture(bs []byte) {
+	if w.limit <= 0 {
+		return
 	}
+	remain := w.limit - int64(w.body.Len())
+	if int64(len(bs)) > remain {
+		bs = bs[:remain]
+		w.truncated = true
+	}
+	w.body.Write(bs)
+}
+
+// Write implements gin.ResponseWriter.
+func (w *dumpResponseWriter) Write(bs []byte) (int, error) {
+	w.capture(bs)
+	return w.ResponseWriter.Write(bs)
+}
+
+// WriteString implements gin.ResponseWriter.
+func (w *dumpResponseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
 }
 
-// mutexHandler is used for GET /debug/pprof/mutex to pprof.
-func mutexHandler() gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		pprof.Handler("mutex").ServeHTTP(ctx.Writer, ctx.Request)
+// dumpHeaders formats given http.Header to sorted "XXX: YYY" string slice, and filters them using dumpRequestOptions.
+func dumpHeaders(header http.Header, opt *dumpRequestOptions) []string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range header[key] {
+			if param, ok := opt.filterHeader(key + ": " + value); ok {
+				result = append(result, param)
+			}
+		}
+	}
+	return result
 }
 
 // ================================
@@ -306,6 +661,49 @@ func AddBinding(tag string, fn validator.Func) error {
 	return v.RegisterValidation(tag, fn)
 }
 
+// AddStructBinding adds user defined struct level validation to gin's validator engine for given types, which can be used for cross-field
+// rules, such as ExactlyOneOfStructValidator and DateAfterStructValidator. The errors reported by validator.StructLevel's ReportError can be
+// translated by AddTranslator using the reported tag, and the reported param will be used as "{1}".
+//
+// Example:
+// 	_ = xgin.AddStructBinding(func(sl validator.StructLevel) {
+// 		req := sl.Current().Interface().(CreateEventRequest)
+// 		if req.EndTime.Before(req.StartTime) {
+// 			sl.ReportError(req.EndTime, "EndTime", "EndTime", "after_start", "StartTime")
+// 		}
+// 	}, CreateEventRequest{})
+// 	_ = xgin.AddTranslator(translator, "after_start", "{0} must be after {1}", true)
+func AddStructBinding(fn validator.StructLevelFunc, types ...interface{}) error {
+	v, err := GetValidatorEngine()
+	if err != nil {
+		return err
+	}
+
+	v.RegisterStructValidation(fn, types...)
+	return nil
+}
+
+// AddCustomTypeFunc adds user defined custom type function to gin's validator engine for given types, which returns the value to be
+// validated for the custom type, such as sql.NullString and other valuer types.
+//
+// Example:
+// 	_ = xgin.AddCustomTypeFunc(func(field reflect.Value) interface{} {
+// 		if valuer, ok := field.Interface().(driver.Valuer); ok {
+// 			val, _ := valuer.Value()
+// 			return val // nil for invalid value
+// 		}
+// 		return nil
+// 	}, sql.NullString{}, sql.NullInt64{})
+func AddCustomTypeFunc(fn validator.CustomTypeFunc, types ...interface{}) error {
+	v, err := GetValidatorEngine()
+	if err != nil {
+		return err
+	}
+
+	v.RegisterCustomTypeFunc(fn, types...)
+	return nil
+}
+
 // AddTranslator adds user defined validator's translator to given ut.Translator using given tag, message and override. Also see
 // xvalidator.AddToTranslatorFunc and xvalidator.DefaultTranslateFunc.
 //
@@ -328,9 +726,10 @@ func EnableParamRegexpBinding() error {
 	return AddBinding("regexp", xvalidator.ParamRegexpValidator())
 }
 
-// EnableParamRegexpBindingTranslator enables parametered regexp validator (`regexp`)'s translator to given ut.Translator.
+// EnableParamRegexpBindingTranslator enables parametered regexp validator (`regexp`)'s translator to given ut.Translator, the message
+// is chosen by translator's locale.
 func EnableParamRegexpBindingTranslator(translator ut.Translator) error {
-	return AddTranslator(translator, "regexp", "{0} must matches regexp /{1}/", true)
+	return addBindingTranslator(translator, "regexp")
 }
 
 // EnableRFC3339DateBinding enables rfc3339 date validator to `date`, see xvalidator.DateTimeValidator.
@@ -338,9 +737,10 @@ func EnableRFC3339DateBinding() error {
 	return AddBinding("date", xvalidator.DateTimeValidator(xtime.RFC3339Date))
 }
 
-// EnableRFC3339DateBindingTranslator enables rfc3339 date validator (`date`)'s translator to given ut.Translator.
+// EnableRFC3339DateBindingTranslator enables rfc3339 date validator (`date`)'s translator to given ut.Translator, the message is chosen
+// by translator's locale.
 func EnableRFC3339DateBindingTranslator(translator ut.Translator) error {
-	return AddTranslator(translator, "date", "{0} must be an RFC3339 date", true)
+	return addBindingTranslator(translator, "date")
 }
 
 // EnableRFC3339DateTimeBinding enables rfc3339 datetime validator to `datetime`, see xvalidator.DateTimeValidator.
@@ -348,7 +748,8 @@ func EnableRFC3339DateTimeBinding() error {
 	return AddBinding("datetime", xvalidator.DateTimeValidator(xtime.RFC3339DateTime))
 }
 
-// EnableRFC3339DateTimeBindingTranslator enables rfc3339 datetime validator (`datetime`)'s translator to given ut.Translator.
+// EnableRFC3339DateTimeBindingTranslator enables rfc3339 datetime validator (`datetime`)'s translator to given ut.Translator, the message
+// is chosen by translator's locale.
 func EnableRFC3339DateTimeBindingTranslator(translator ut.Translator) error {
-	return AddTranslator(translator, "datetime", "{0} must be an RFC3339 datetime", true)
+	return addBindingTranslator(translator, "datetime")
 }
@@ -15,8 +15,10 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -81,51 +83,240 @@ func TestDumpRequest(t *testing.T) {
 	}
 }
 
-func TestPprofWrap(t *testing.T) {
+func TestDumpRequestHeaderPattern(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	xtesting.Panic(t, func() { WithIgnoreHeaders("/[/") })
+	xtesting.Panic(t, func() { WithRetainHeaders("X-[") })
+	xtesting.Panic(t, func() { WithSecretHeaders("X-A", "/(/") })
+	xtesting.NotPanic(t, func() { WithSecretHeaders("X-A", "/^X-(A|B)$/", "X-*") })
+
+	for _, tc := range []struct {
+		giveOptions []DumpRequestOption
+		want        []string
+	}{
+		{nil, []string{"GET / HTTP/1.1", "Host: example.com", "Authorization: Bearer token", "X-Internal-Id: 1", "X-Internal-Token: 2", "X-Public: 3"}},
+		{[]DumpRequestOption{WithRetainHeaders("authorization", "HOST")}, []string{"GET / HTTP/1.1", "Host: example.com", "Authorization: Bearer token"}},
+		{[]DumpRequestOption{WithRetainHeaders("x-internal-*")}, []string{"GET / HTTP/1.1", "X-Internal-Id: 1", "X-Internal-Token: 2"}},
+		{[]DumpRequestOption{WithIgnoreHeaders("X-Internal-*", "host")}, []string{"GET / HTTP/1.1", "Authorization: Bearer token", "X-Public: 3"}},
+		{[]DumpRequestOption{WithIgnoreHeaders("/^x-(internal|public)/")}, []string{"GET / HTTP/1.1", "Host: example.com", "Authorization: Bearer token"}},
+		{[]DumpRequestOption{WithIgnoreHeaders("/^X-.+-Id$/", "Host"), WithSecretHeaders("/token$/", "AUTHORIZATION")},
+			[]string{"GET / HTTP/1.1", "Authorization: *", "X-Internal-Token: *", "X-Public: 3"}},
+		{[]DumpRequestOption{WithRetainHeaders("Authorization", "X-Internal-Token"), WithSecretHeaders("authorization", "x-internal-token"), WithSecretPartialMask(true)},
+			[]string{"GET / HTTP/1.1", "Authorization: Bearer *", "X-Internal-Token: *"}},
+		{[]DumpRequestOption{WithRetainHeaders("Authorization"), WithSecretHeaders("Authorization"), WithSecretPartialMask(true), WithSecretReplace("****")},
+			[]string{"GET / HTTP/1.1", "Authorization: Bearer ****"}},
+	} {
+		app := gin.New()
+		app.GET("/", func(c *gin.Context) {
+			c.JSON(200, DumpRequest(c, tc.giveOptions...))
+		})
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header = http.Header{
+			"authorization":    []string{"Bearer token"}, // lower case
+			"X-Internal-Id":    []string{"1"},
+			"x-internal-token": []string{"2"},
+			"X-Public":         []string{"3"},
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		result := make([]string, 0)
+		xtesting.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+		xtesting.ElementMatch(t, result, tc.want)
+		xtesting.Equal(t, result[0], tc.want[0])
+	}
+}
+
+func TestDumpRequestBody(t *testing.T) {
 	gin.SetMode(gin.ReleaseMode)
 	app := gin.New()
-	PprofWrap(app)
-	server := &http.Server{Addr: ":12345", Handler: app}
-	go server.ListenAndServe()
-	defer server.Shutdown(context.Background())
+	app.POST("", func(c *gin.Context) {
+		var opts []DumpRequestOption
+		switch c.Query("opt") {
+		case "none":
+		case "limit":
+			opts = []DumpRequestOption{WithBodyLimit(8)}
+		case "secret":
+			opts = []DumpRequestOption{WithBodyLimit(1024), WithSecretFields("password", "token")}
+		case "replace":
+			opts = []DumpRequestOption{WithBodyLimit(1024), WithSecretFields("password"), WithSecretReplace("***")}
+		default:
+			opts = []DumpRequestOption{WithBodyLimit(1024)}
+		}
+		dumped := DumpRequest(c, append(opts, WithRetainHeaders("Content-Type"))...)
+		bs, _ := ioutil.ReadAll(c.Request.Body) // body can still be read
+		c.JSON(200, gin.H{"dump": dumped, "body": string(bs)})
+	})
 
 	for _, tc := range []struct {
-		giveMethod string
-		giveUrl    string
+		giveOpt  string
+		giveType string
+		giveBody string
+		wantDump []interface{}
 	}{
-		{"GET", "debug/pprof/"},
-		{"GET", "debug/pprof/heap"},
-		{"GET", "debug/pprof/goroutine"},
-		{"GET", "debug/pprof/allocs"},
-		{"GET", "debug/pprof/block"},
-		{"GET", "debug/pprof/threadcreate"},
-		{"GET", "debug/pprof/cmdline"},
-		// {"GET", "debug/pprof/profile"}, // <<< too slow
-		{"GET", "debug/pprof/symbol"},
-		{"POST", "debug/pprof/symbol"},
-		{"GET", "debug/pprof/trace"},
-		{"GET", "debug/pprof/mutex"},
+		{"none", "application/json", `{"a":1}`, []interface{}{"POST /?opt=none HTTP/1.1", "Content-Type: application/json"}},
+		{"", "application/json", ``, []interface{}{"POST /? HTTP/1.1", "Content-Type: application/json"}},
+		{"", "application/json", `{"a":1}`, []interface{}{"POST /? HTTP/1.1", "Content-Type: application/json", "", `{"a":1}`}},
+		{"limit", "text/plain", `0123456789`, []interface{}{"POST /?opt=limit HTTP/1.1", "Content-Type: text/plain", "", `01234567...`}},
+		{"limit", "text/plain", `01234567`, []interface{}{"POST /?opt=limit HTTP/1.1", "Content-Type: text/plain", "", `01234567`}},
+		{"secret", "application/json", `{"user": "a", "password" : "p\"w", "token":123, "nested": {"token": null}}`,
+			[]interface{}{"POST /?opt=secret HTTP/1.1", "Content-Type: application/json", "", `{"user": "a", "password" : "*", "token":"*", "nested": {"token": "*"}}`}},
+		{"secret", "application/json", `{"password": {"old": "o", "new": "n"}, "token": ["a", {"b": 1}], "list": [{"token": "<t>"}, 1.50]}`,
+			[]interface{}{"POST /?opt=secret HTTP/1.1", "Content-Type: application/json", "", `{"password": "*", "token": "*", "list": [{"token": "*"}, 1.50]}`}},
+		{"secret", "application/x-www-form-urlencoded", `user=a&password=p%26w&token=t&x`,
+			[]interface{}{"POST /?opt=secret HTTP/1.1", "Content-Type: application/x-www-form-urlencoded", "", `user=a&password=*&token=*&x`}},
+		{"secret", "text/plain", `password=p`, []interface{}{"POST /?opt=secret HTTP/1.1", "Content-Type: text/plain", "", `password=p`}},
+		{"replace", "application/json", `{"password":"p","token":"t"}`,
+			[]interface{}{"POST /?opt=replace HTTP/1.1", "Content-Type: application/json", "", `{"password":"***","token":"t"}`}},
 	} {
-		var resp *http.Response
-		var err error
-		url := "http://localhost:12345/" + tc.giveUrl
-		if tc.giveMethod == http.MethodGet {
-			resp, err = http.Get(url)
-		} else if tc.giveMethod == http.MethodPost {
-			resp, err = http.Post(url, "application/json", nil)
+		url := "/?"
+		if tc.giveOpt != "" {
+			url += "opt=" + tc.giveOpt
 		}
-		xtesting.Nil(t, err)
-		if err == nil {
-			xtesting.Equal(t, resp.StatusCode, 200)
+		req := httptest.NewRequest("POST", url, strings.NewReader(tc.giveBody))
+		req.Header.Set("Content-Type", tc.giveType)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+
+		result := make(map[string]interface{})
+		xtesting.Nil(t, json.Unmarshal(w.Body.Bytes(), &result))
+		xtesting.Equal(t, result["dump"], tc.wantDump)
+		xtesting.Equal(t, result["body"], tc.giveBody)
+	}
+
+	t.Run("maskBodyFields", func(t *testing.T) {
+		opt := newDumpRequestOptions([]DumpRequestOption{WithSecretFields("password", "$a"), WithSecretReplace("$1")})
+		for _, tc := range []struct {
+			giveBody string
+			giveType string
+			want     string
+		}{
+			{`{"password": "p", "$a": [1, 2]}`, "application/json", `{"password": "$1", "$a": "$1"}`},
+			{`[{"a": {"password": null}}, "password"]`, "application/json; charset=utf-8", `[{"a": {"password": "$1"}}, "password"]`},
+			{" {\"a\\\"\" :\t{ \"password\" : { } } ,\"b\":[ ]}\n", "application/json", " {\"a\\\"\" :\t{ \"password\" : \"$1\" } ,\"b\":[ ]}\n"},
+			{`{"password": "p", "a": "b", "$a": 1`, "application/json", `{"password": "$1", "a": "b", "$a": "$1"`}, // truncated, use regexps
+			{`{"password": "p"} {}`, "application/json", `{"password": "$1"} {}`},
+			{`password=p&%24a=b`, "application/x-www-form-urlencoded", `password=$1&%24a=$1`},
+		} {
+			xtesting.Equal(t, maskBodyFields(tc.giveBody, tc.giveType, opt), tc.want)
+		}
+	})
+}
+
+func TestDumpResponse(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	var dumped *DumpedResponse
+	callback := func(c *gin.Context, resp *DumpedResponse) { dumped = resp }
+
+	app := gin.New()
+	app.GET("none", DumpResponse(callback), func(c *gin.Context) {
+		c.Header("X-Test", "test")
+		c.String(201, "hello")
+	})
+	app.GET("limit", DumpResponse(callback, WithBodyLimit(4), WithIgnoreHeaders("Content-Type")), func(c *gin.Context) {
+		c.String(200, "hello")
+	})
+	app.GET("secret", DumpResponse(callback, WithBodyLimit(1024), WithSecretHeaders("Set-Cookie"), WithSecretFields("token")), func(c *gin.Context) {
+		c.Header("Set-Cookie", "a=b")
+		c.JSON(200, gin.H{"token": "abc", "user": "a"})
+	})
+
+	for _, tc := range []struct {
+		giveEp     string
+		wantStatus int
+		wantHeader []string
+		wantBody   string
+		wantResp   string
+	}{
+		{"none", 201, []string{"Content-Type: text/plain; charset=utf-8", "X-Test: test"}, "", "hello"},
+		{"limit", 200, []string{}, "hell...", "hello"},
+		{"secret", 200, []string{"Content-Type: application/json; charset=utf-8", "Set-Cookie: *"}, `{"token":"*","user":"a"}`, `{"token":"abc","user":"a"}`},
+	} {
+		dumped = nil
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest("GET", "/"+tc.giveEp, nil))
+		xtesting.Equal(t, w.Body.String(), tc.wantResp)
+		if xtesting.NotNil(t, dumped) {
+			xtesting.Equal(t, dumped.Status, tc.wantStatus)
+			xtesting.Equal(t, dumped.Headers, tc.wantHeader)
+			xtesting.Equal(t, dumped.Body, tc.wantBody)
 		}
 	}
 
-	// for slow debug/pprof/profile
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-	req, _ := http.NewRequestWithContext(ctx, "GET", "http://localhost:12345/debug/pprof/profile", nil) // after go113
-	client := &http.Client{}
-	_, _ = client.Do(req) // ignore result
+	// get from context and log
+	l := logrus.New()
+	l.SetOutput(ioutil.Discard)
+	app2 := gin.New()
+	app2.Use(func(c *gin.Context) {
+		xtesting.Nil(t, WithDumpedResponse(c))
+		start := time.Now()
+		c.Next()
+		opt := WithDumpedResponse(c)
+		xtesting.NotNil(t, opt)
+		LogToLogrus(l, c, start, time.Now(), WithExtraFieldsV("k", "v"), opt)
+		resp, ok := GetDumpedResponse(c)
+		xtesting.True(t, ok)
+		xtesting.Equal(t, resp.Headers, []string{"X-A: a"})
+		xtesting.Equal(t, resp.Body, "ab")
+	})
+	app2.Use(DumpResponse(nil, WithBodyLimit(1024), WithRetainHeaders("X-A")))
+	app2.GET("", func(c *gin.Context) {
+		c.Header("X-A", "a")
+		c.Header("X-B", "b")
+		_, _ = c.Writer.Write([]byte("a"))
+		_, _ = c.Writer.WriteString("b")
+	})
+	w := httptest.NewRecorder()
+	app2.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	xtesting.Equal(t, w.Body.String(), "ab")
+}
+
+func TestDumpCurlCommand(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	xtesting.Equal(t, DumpCurlCommand(nil), "")
+	xtesting.Equal(t, shellQuote(""), "''")
+	xtesting.Equal(t, shellQuote("abc-_./:=@,+%"), "abc-_./:=@,+%")
+	xtesting.Equal(t, shellQuote("a b"), "'a b'")
+	xtesting.Equal(t, shellQuote("it's"), `'it'\''s'`)
+
+	for _, tc := range []struct {
+		giveMethod  string
+		giveUrl     string
+		giveHeaders map[string]string
+		giveBody    string
+		giveOptions []DumpRequestOption
+		want        string
+	}{
+		{"GET", "/", nil, "", nil, "curl -X GET http://example.com/"},
+		{"GET", "/a?b=c&d=e", map[string]string{"Accept": "*/*"}, "", nil,
+			"curl -X GET 'http://example.com/a?b=c&d=e' -H 'Accept: */*'"},
+		{"GET", "/", map[string]string{"X-A": "a", "X-B": "b"}, "", []DumpRequestOption{WithIgnoreHeaders("X-A")},
+			"curl -X GET http://example.com/ -H 'X-B: b'"},
+		{"GET", "/", map[string]string{"Authorization": "Bearer xxx", "X-B": "b"}, "", []DumpRequestOption{WithSecretHeaders("Authorization")},
+			"curl -X GET http://example.com/ -H 'Authorization: *' -H 'X-B: b'"},
+		{"POST", "/", map[string]string{"Content-Type": "application/json"}, `{"a": "it's"}`, nil,
+			"curl -X POST http://example.com/ -H 'Content-Type: application/json'"},
+		{"POST", "/", map[string]string{"Content-Type": "application/json"}, `{"a": "it's"}`, []DumpRequestOption{WithBodyLimit(1024)},
+			`curl -X POST http://example.com/ -H 'Content-Type: application/json' --data-raw '{"a": "it'\''s"}'`},
+		{"POST", "/", map[string]string{"Content-Type": "application/json"}, `{"password": "p"}`, []DumpRequestOption{WithBodyLimit(1024), WithSecretFields("password")},
+			`curl -X POST http://example.com/ -H 'Content-Type: application/json' --data-raw '{"password": "*"}'`},
+		{"POST", "/", map[string]string{"Content-Type": "application/json"}, `{"a": "0123456789"}`, []DumpRequestOption{WithBodyLimit(8)},
+			`curl -X POST http://example.com/ -H 'Content-Type: application/json' # body is omitted, because it is larger than 8 bytes`},
+		{"POST", "/", map[string]string{"Content-Type": "application/json"}, `{"a": 1}`, []DumpRequestOption{WithBodyLimit(8)},
+			`curl -X POST http://example.com/ -H 'Content-Type: application/json' --data-raw '{"a": 1}'`},
+	} {
+		app := gin.New()
+		app.Any("/*any", func(c *gin.Context) {
+			c.String(200, DumpCurlCommand(c, tc.giveOptions...))
+		})
+		req := httptest.NewRequest(tc.giveMethod, tc.giveUrl, strings.NewReader(tc.giveBody))
+		req.ContentLength = int64(len(tc.giveBody))
+		for k, v := range tc.giveHeaders {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		xtesting.Equal(t, w.Body.String(), tc.want)
+	}
 }
 
 func TestRequiredAndOmitempty(t *testing.T) {
@@ -398,3 +589,94 @@ func TestLogger(t *testing.T) {
 		_, _ = http.Post("http://127.0.0.1:12345/XX", "application/json", nil)
 	}
 }
+
+func TestRecordValidationErrors(t *testing.T) {
+	type testStruct struct {
+		Name     string   `json:"name"     binding:"required"`
+		Password string   `json:"password" binding:"min=8"`
+		Age      int      `json:"age"      binding:"min=18"`
+		Bio      string   `json:"bio"      binding:"max=3"`
+		Tags     []string `json:"tags"     binding:"max=2"`
+		Email    string   `json:"email"    binding:"omitempty,email"`
+	}
+	gin.SetMode(gin.ReleaseMode)
+	buf := &strings.Builder{}
+	l := logrus.New()
+	l.SetOutput(buf)
+	l.SetFormatter(&logrus.JSONFormatter{})
+	app := gin.New()
+	app.Use(func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		LogToLogrus(l, c, start, time.Now())
+	})
+	app.POST("/", func(c *gin.Context) {
+		obj := &testStruct{}
+		if err := ShouldBindJSON(c, obj); err != nil {
+			RecordValidationErrors(c, err, obj, WithValidationPlainFields("age", "bio", "password"), WithValidationSecretFields("PASSWORD"), WithValidationSecretReplace("***"))
+			RecordValidationErrors(c, errors.New("test"), obj)
+			c.Status(400)
+			return
+		}
+		c.Status(200)
+	})
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"password": "123", "age": 3, "bio": "`+strings.Repeat("x", 40)+`", "tags": ["a", "b", "c"], "email": "secret@"}`))
+	req.Header.Set("Content-Type", "application/json")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+	entry := make(map[string]interface{})
+	xtesting.Nil(t, json.Unmarshal([]byte(buf.String()), &entry))
+	xtesting.Equal(t, entry["validation_errors"], []interface{}{
+		map[string]interface{}{"field": "name", "tag": "required", "param": "", "value": "string(len=0)"},
+		map[string]interface{}{"field": "password", "tag": "min", "param": "8", "value": "***"},
+		map[string]interface{}{"field": "age", "tag": "min", "param": "18", "value": "3"},
+		map[string]interface{}{"field": "bio", "tag": "max", "param": "3", "value": strings.Repeat("x", 32) + "..."},
+		map[string]interface{}{"field": "tags", "tag": "max", "param": "2", "value": "[]string(len=3)"},
+		map[string]interface{}{"field": "email", "tag": "email", "param": "", "value": "string(len=7)"},
+	})
+	xtesting.True(t, strings.HasSuffix(entry["msg"].(string), " | validation: name(required), password(min=8), age(min=18), bio(max=3), tags(max=2), email(email)"))
+
+	// no failures
+	buf.Reset()
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "a", "password": "12345678", "age": 18}`))
+	req.Header.Set("Content-Type", "application/json")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+	entry = make(map[string]interface{})
+	xtesting.Nil(t, json.Unmarshal([]byte(buf.String()), &entry))
+	_, ok := entry["validation_errors"]
+	xtesting.False(t, ok)
+
+	// bind error and accessor
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, ok = GetValidationFailures(ctx)
+	xtesting.False(t, ok)
+	RecordValidationErrors(ctx, &BindError{Kind: BindErrorValidation, Fields: []*FieldError{{Field: "x", Tag: "required"}, {Field: "y"}}}, nil, WithValidationPlainFields("x"), nil)
+	failures, ok := GetValidationFailures(ctx)
+	xtesting.True(t, ok)
+	xtesting.Equal(t, failures, []*ValidationFailure{{Field: "x", Tag: "required"}})
+	for _, tc := range []struct {
+		give interface{}
+		want string
+	}{
+		{nil, "<nil>"},
+		{(*int)(nil), "<nil>"},
+		{new(int), "0"},
+		{map[string]int{"a": 1}, "map[string]int(len=1)"},
+		{"你好", "你好"},
+	} {
+		xtesting.Equal(t, summarizeValue(tc.give), tc.want)
+	}
+	for _, tc := range []struct {
+		give interface{}
+		want string
+	}{
+		{nil, "<nil>"},
+		{(*int)(nil), "<nil>"},
+		{new(int), "int"},
+		{map[string]int{"a": 1}, "map[string]int(len=1)"},
+		{"你好", "string(len=6)"},
+		{[2]bool{}, "[2]bool(len=2)"},
+	} {
+		xtesting.Equal(t, redactValue(tc.give), tc.want)
+	}
+}